@@ -0,0 +1,112 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// CheckState is the outcome of Check, doubling as the exit code of the
+// check command.
+type CheckState int
+
+const (
+	// CheckUpToDate every migration is applied.
+	CheckUpToDate CheckState = iota
+	// CheckPending some migrations are not applied yet.
+	CheckPending
+	// CheckUnknown the database has applied migrations missing from the
+	// source, or changed in the source since they were applied.
+	CheckUnknown
+	// CheckFailed the database could not be reached or read.
+	CheckFailed
+)
+
+// CheckResult tells whether a database is up to date with its migrations.
+type CheckResult struct {
+	State CheckState
+	// Version is the Id of the newest applied migration, empty when none is.
+	Version string
+	// Pending ids of the migrations not applied yet.
+	Pending []string
+	// Unknown ids of the applied migrations missing from the source.
+	Unknown []string
+	// Changed ids of the applied migrations whose checksum differs from
+	// the source.
+	Changed []string
+	Err     error
+}
+
+// String returns a one line summary of the result.
+func (r *CheckResult) String() string {
+	version := r.Version
+	if version == "" {
+		version = "none"
+	}
+	switch r.State {
+	case CheckUpToDate:
+		return fmt.Sprintf("Database is up to date, version %s", version)
+	case CheckPending:
+		return fmt.Sprintf("%d pending migrations, version %s", len(r.Pending), version)
+	case CheckUnknown:
+		var problems []string
+		if len(r.Unknown) > 0 {
+			problems = append(problems, fmt.Sprintf("%d unknown migrations in database (%s)", len(r.Unknown), strings.Join(r.Unknown, ", ")))
+		}
+		if len(r.Changed) > 0 {
+			problems = append(problems, fmt.Sprintf("%d migrations changed since applied (%s)", len(r.Changed), strings.Join(r.Changed, ", ")))
+		}
+		return fmt.Sprintf("%s, version %s", strings.Join(problems, ", "), version)
+	default:
+		return fmt.Sprintf("Cannot check the database: %s", r.Err)
+	}
+}
+
+// Check compares the applied migrations with those of the source, without
+// modifying the database, not even to create the migration table.
+func Check(db *sql.DB, dialect string, m MigrationSource) *CheckResult {
+	return migSet.Check(db, dialect, m)
+}
+
+func (ms MigrationSet) Check(db *sql.DB, dialect string, m MigrationSource) *CheckResult {
+	failed := func(err error) *CheckResult {
+		return &CheckResult{State: CheckFailed, Err: err}
+	}
+
+	if db == nil {
+		return failed(ErrNoDatabase)
+	}
+	if err := db.Ping(); err != nil {
+		return failed(err)
+	}
+	migrations, err := m.FindMigrations()
+	if err != nil {
+		return failed(err)
+	}
+	records, err := ms.appliedRecords(db, dialect)
+	if err != nil {
+		return failed(err)
+	}
+
+	report, err := newStatusReport(migrations, records)
+	if err != nil {
+		return failed(err)
+	}
+	result := &CheckResult{Unknown: report.Unknown, Changed: report.Changed}
+	for _, row := range report.Migrations {
+		if row.Applied {
+			result.Version = row.Id
+		} else {
+			result.Pending = append(result.Pending, row.Id)
+		}
+	}
+	switch {
+	case len(result.Unknown) > 0 || len(result.Changed) > 0:
+		result.State = CheckUnknown
+	case len(result.Pending) > 0:
+		result.State = CheckPending
+	default:
+		result.State = CheckUpToDate
+	}
+	return result
+}
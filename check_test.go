@@ -0,0 +1,94 @@
+package migration
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var checkMigrations = []*Migration{
+	{Id: "1_create_users.sql", Up: []string{"CREATE TABLE users (id INTEGER PRIMARY KEY)"}},
+	{Id: "2_create_posts.sql", Up: []string{"CREATE TABLE posts (id INTEGER PRIMARY KEY)"}},
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied []*Migration
+		source  []*Migration
+		state   CheckState
+		summary string
+	}{
+		{"fresh database", nil, checkMigrations, CheckPending, "2 pending migrations, version none"},
+		{"pending", checkMigrations[:1], checkMigrations, CheckPending, "1 pending migrations, version 1_create_users.sql"},
+		{"up to date", checkMigrations, checkMigrations, CheckUpToDate, "Database is up to date, version 2_create_posts.sql"},
+		{"unknown", checkMigrations, checkMigrations[:1], CheckUnknown, "1 unknown migrations in database (2_create_posts.sql), version 1_create_users.sql"},
+		{
+			"changed",
+			checkMigrations,
+			[]*Migration{checkMigrations[0], {Id: "2_create_posts.sql", Up: []string{"CREATE TABLE posts (id INTEGER PRIMARY KEY, title TEXT)"}}},
+			CheckUnknown,
+			"1 migrations changed since applied (2_create_posts.sql), version 2_create_posts.sql",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useMockUi(t)
+			db := openTestDB(t)
+			ms := MigrationSet{}
+			if len(test.applied) > 0 {
+				if _, err := ms.Exec(db, "sqlite3", MemoryMigrationSource{Migrations: test.applied}, Up); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			result := ms.Check(db, "sqlite3", MemoryMigrationSource{Migrations: test.source})
+			if result.State != test.state || result.String() != test.summary {
+				t.Errorf("got %d %q, want %d %q", result.State, result, test.state, test.summary)
+			}
+			if len(test.applied) == 0 && tableExists(t, db, "gorp_migrations") {
+				t.Error("check created the tracking table")
+			}
+		})
+	}
+
+	result := MigrationSet{}.Check(nil, "sqlite3", MemoryMigrationSource{})
+	if result.State != CheckFailed || result.Err != ErrNoDatabase {
+		t.Errorf("check without database = %d, %v", result.State, result.Err)
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.db")
+	source := MemoryMigrationSource{Migrations: checkMigrations}
+
+	m := New(Config{Dialect: "sqlite3", MigrationSource: source})
+	mock := useMockUi(t)
+	check := &CheckCommand{migrate: m}
+	args := []string{"-dsn", file}
+
+	if code := check.Run(args); code != int(CheckPending) {
+		t.Errorf("exit code %d before up, want %d", code, CheckPending)
+	}
+	db := m.DB
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := (MigrationSet{TableName: m.TableName}).Exec(db, "sqlite3", source, Up); err != nil {
+		t.Fatal(err)
+	}
+	if code := check.Run(args); code != int(CheckUpToDate) {
+		t.Errorf("exit code %d after up, want %d", code, CheckUpToDate)
+	}
+	reopened := m.DB
+	t.Cleanup(func() { _ = reopened.Close() })
+	if !strings.Contains(mock.OutputWriter.String(), "Database is up to date") {
+		t.Errorf("output %q", mock.OutputWriter.String())
+	}
+
+	m.DB, m.dataSource = nil, ""
+	if code := (&CheckCommand{migrate: m}).Run(nil); code != int(CheckFailed) {
+		t.Errorf("exit code %d without database, want %d", code, CheckFailed)
+	}
+	if code := check.Run([]string{"-bogus"}); code != int(CheckFailed) {
+		t.Errorf("exit code %d with a bad flag, want %d", code, CheckFailed)
+	}
+}
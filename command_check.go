@@ -0,0 +1,67 @@
+package migration
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+type CheckCommand struct {
+	migrate *Migrate
+}
+
+func (c *CheckCommand) Help() string {
+	helpText := `
+Usage: %s check [options] ...
+
+  Check whether the database is up to date, without modifying it.
+
+  Exits with 0 when every migration is applied, 1 when migrations are
+  pending, 2 when the database has unknown migrations or migrations changed
+  since they were applied, and 3 when the database cannot be reached or
+  read.
+
+Options:
+
+  -config=dbconfig.yml   Configuration file to use.
+  -env="development"     Environment.
+  -dialect=""            Dialect, overriding the configuration file.
+  -dsn=""                Connection string, overriding the configuration file.
+  -dir=""                Migrations directory, overriding the configuration file.
+  -table=""              Migration table, overriding the configuration file.
+  -schema=""             Schema of the migration table, overriding the configuration file.
+
+`
+	return strings.TrimSpace(fmt.Sprintf(helpText, Cmd))
+}
+
+func (c *CheckCommand) Synopsis() string {
+	return "Check whether the database is up to date"
+}
+
+func (c *CheckCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("check", flag.ContinueOnError)
+	cmdFlags.Usage = func() { ui.Output(c.Help()) }
+
+	var config configFlags
+	config.register(cmdFlags)
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return int(CheckFailed)
+	}
+	if err := config.connect(c.migrate); err != nil {
+		ui.Error(err.Error())
+		return int(CheckFailed)
+	}
+
+	result := c.migrate.Check()
+	switch result.State {
+	case CheckUpToDate:
+		ui.Output(result.String())
+	case CheckPending:
+		ui.Warn(result.String())
+	default:
+		ui.Error(result.String())
+	}
+	return int(result.State)
+}
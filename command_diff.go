@@ -0,0 +1,80 @@
+package migration
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+type DiffCommand struct {
+	migrate *Migrate
+}
+
+func (c *DiffCommand) Help() string {
+	helpText := `
+Usage: %s diff [options] schema.sql
+
+  Generate a migration that brings the current schema to the one described
+  by schema.sql, a file of CREATE TABLE, CREATE INDEX and CREATE VIEW
+  statements.
+
+Options:
+
+  -config=dbconfig.yml   Configuration file to use.
+  -env="development"     Environment.
+  -dialect=""            Dialect, overriding the configuration file.
+  -dsn=""                Connection string, overriding the configuration file.
+  -dir=""                Migrations directory, overriding the configuration file.
+  -table=""              Migration table, overriding the configuration file.
+  -schema=""             Schema of the migration table, overriding the configuration file.
+  -from=migrations       Current schema: "migrations" replays the existing
+                         migrations, "database" introspects the database.
+  -name=schema_diff      Name of the generated migration.
+
+`
+	return strings.TrimSpace(fmt.Sprintf(helpText, Cmd))
+}
+
+func (c *DiffCommand) Synopsis() string {
+	return "Generate a migration from a desired schema"
+}
+
+func (c *DiffCommand) Run(args []string) int {
+	var from string
+	var name string
+
+	cmdFlags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	cmdFlags.Usage = func() { ui.Output(c.Help()) }
+	cmdFlags.StringVar(&from, "from", "migrations", "Current schema: migrations or database.")
+	cmdFlags.StringVar(&name, "name", "schema_diff", "Name of the generated migration.")
+
+	var config configFlags
+	config.register(cmdFlags)
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if err := config.configure(c.migrate); err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+
+	if cmdFlags.NArg() < 1 {
+		err := errors.New("A schema file is needed")
+		ui.Error(err.Error())
+		return 1
+	}
+
+	if from == "database" {
+		if err := c.migrate.connect(); err != nil {
+			ui.Error(err.Error())
+			return 1
+		}
+	}
+	if err := c.migrate.Diff(cmdFlags.Arg(0), from, name); err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+package migration
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+type GenerateCommand struct {
+	migrate *Migrate
+}
+
+func (c *GenerateCommand) Help() string {
+	helpText := `
+Usage: %s generate [options] path
+
+  Generate a migration from annotated Go structs.
+
+  The structs in path, a package directory or a .go file, are compared to
+  the schema implied by the existing migrations. Columns are described with
+  'db' and 'migrate' struct tags, e.g.
+
+    Email string ` + "`" + `db:"email" migrate:"size:255;unique"` + "`" + `
+
+Options:
+
+  -config=dbconfig.yml   Configuration file to use.
+  -env="development"     Environment.
+  -dialect=""            Dialect, overriding the configuration file.
+  -dsn=""                Connection string, overriding the configuration file.
+  -dir=""                Migrations directory, overriding the configuration file.
+  -table=""              Migration table, overriding the configuration file.
+  -schema=""             Schema of the migration table, overriding the configuration file.
+  -name=sync_models      Name of the generated migration.
+
+`
+	return strings.TrimSpace(fmt.Sprintf(helpText, Cmd))
+}
+
+func (c *GenerateCommand) Synopsis() string {
+	return "Generate a migration from Go structs"
+}
+
+func (c *GenerateCommand) Run(args []string) int {
+	var name string
+
+	cmdFlags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	cmdFlags.Usage = func() { ui.Output(c.Help()) }
+	cmdFlags.StringVar(&name, "name", "sync_models", "Name of the generated migration.")
+
+	var config configFlags
+	config.register(cmdFlags)
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if err := config.configure(c.migrate); err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+
+	if cmdFlags.NArg() < 1 {
+		err := errors.New("A path to the models is needed")
+		ui.Error(err.Error())
+		return 1
+	}
+
+	if err := c.migrate.GenerateFromModels(cmdFlags.Arg(0), name); err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,63 @@
+package migration
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+type GraphCommand struct {
+	migrate *Migrate
+}
+
+func (c *GraphCommand) Help() string {
+	helpText := `
+Usage: %s graph [options] ...
+
+  Print the migration dependency graph.
+
+Options:
+
+  -config=dbconfig.yml   Configuration file to use.
+  -env="development"     Environment.
+  -dialect=""            Dialect, overriding the configuration file.
+  -dsn=""                Connection string, overriding the configuration file.
+  -dir=""                Migrations directory, overriding the configuration file.
+  -table=""              Migration table, overriding the configuration file.
+  -schema=""             Schema of the migration table, overriding the configuration file.
+  -format=dot            Output format (dot or mermaid).
+
+`
+	return strings.TrimSpace(fmt.Sprintf(helpText, Cmd))
+}
+
+func (c *GraphCommand) Synopsis() string {
+	return "Print the migration dependency graph"
+}
+
+func (c *GraphCommand) Run(args []string) int {
+	var format string
+
+	cmdFlags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	cmdFlags.Usage = func() { ui.Output(c.Help()) }
+	cmdFlags.StringVar(&format, "format", GraphFormatDot, "Output format (dot or mermaid).")
+
+	var config configFlags
+	config.register(cmdFlags)
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if err := config.configure(c.migrate); err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+
+	err := c.migrate.Graph(format)
+	if err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,73 @@
+package migration
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+type LintCommand struct {
+	migrate *Migrate
+}
+
+func (c *LintCommand) Help() string {
+	helpText := `
+Usage: %s lint [options] ...
+
+  Check the migration files for common mistakes, without a database.
+
+  Findings are suppressed with a '-- lint:ignore ML002' comment on or right
+  above the statement, or for a whole file with '-- lint:ignore-file ML002'.
+
+Options:
+
+  -config=dbconfig.yml   Configuration file to use.
+  -env="development"     Environment.
+  -dialect=""            Dialect, overriding the configuration file.
+  -dsn=""                Connection string, overriding the configuration file.
+  -dir=""                Migrations directory, overriding the configuration file.
+  -table=""              Migration table, overriding the configuration file.
+  -schema=""             Schema of the migration table, overriding the configuration file.
+  -rules                 List the rules and exit.
+
+`
+	return strings.TrimSpace(fmt.Sprintf(helpText, Cmd))
+}
+
+func (c *LintCommand) Synopsis() string {
+	return "Check the migration files for common mistakes"
+}
+
+func (c *LintCommand) Run(args []string) int {
+	var rules bool
+
+	cmdFlags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	cmdFlags.Usage = func() { ui.Output(c.Help()) }
+	cmdFlags.BoolVar(&rules, "rules", false, "List the rules and exit.")
+
+	var config configFlags
+	config.register(cmdFlags)
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if err := config.configure(c.migrate); err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+
+	if rules {
+		for _, rule := range LintRules {
+			ui.Output(fmt.Sprintf("%s  %-7s  %s", rule.ID, rule.Severity, rule.Description))
+		}
+		return 0
+	}
+
+	err := c.migrate.Lint()
+	if err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
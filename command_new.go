@@ -29,10 +29,33 @@ Usage: %s new [options] name
 
   Create a new a database migration.
 
+  With -template, the migration is rendered from the text/template
+  TEMPLATES/<template>.sql.tmpl, with the fields .Name, .Table, .Timestamp,
+  .Dialect, .Author and .Vars (the -var flags).
+
+  Otherwise the statements are guessed from the name for the dialect:
+  create_<table>_table, drop_<table>_table, add_<column>_in_<table>_table,
+  remove_<column>_from_<table>_table, alter_<column>_in_<table>_table,
+  rename_<old>_to_<new>_in_<table>_table and rename_<old>_in_<new>_table.
+
 Options:
 
   -config=dbconfig.yml   Configuration file to use.
   -env="development"     Environment.
+  -dialect=""            Dialect, overriding the configuration file.
+  -dsn=""                Connection string, overriding the configuration file.
+  -dir=""                Migrations directory, overriding the configuration file.
+  -table=""              Migration table, overriding the configuration file.
+  -schema=""             Schema of the migration table, overriding the configuration file.
+  -template=""           Template to render the migration from.
+  -templates=""          Directory of the templates (default: ./database/templates).
+  -var key=value         Variable of the template, may be repeated.
+  -columns=""            Columns of a create_<table>_table, add_<column>_in_<table>_table
+                         or alter_<column>_in_<table>_table migration, as
+                         name:type[:modifier...] separated by commas, e.g.
+                         "email:string:unique,age:int:null,org_id:ref:orgs".
+  -naming=timestamp      Naming strategy (timestamp or sequential).
+  -padding=4             Digits of sequential numbers.
   name                   The name of the migration
 `
 	return strings.TrimSpace(fmt.Sprintf(helpText, Cmd))
@@ -43,20 +66,66 @@ func (c *NewCommand) Synopsis() string {
 }
 
 func (c *NewCommand) Run(args []string) int {
+	var templateName string
+	var templatesDir string
+	vars := templateVars{}
+	var columns string
+	var naming string
+	var padding int
+
 	cmdFlags := flag.NewFlagSet("new", flag.ContinueOnError)
 	cmdFlags.Usage = func() { ui.Output(c.Help()) }
+	cmdFlags.StringVar(&templateName, "template", "", "Template to render the migration from.")
+	cmdFlags.StringVar(&templatesDir, "templates", "", "Directory of the templates.")
+	cmdFlags.Var(vars, "var", "Variable of the template, as key=value.")
+	cmdFlags.StringVar(&columns, "columns", "", "Columns of a create, add or alter migration.")
+	cmdFlags.StringVar(&naming, "naming", "", "Naming strategy (timestamp or sequential).")
+	cmdFlags.IntVar(&padding, "padding", 0, "Digits of sequential numbers.")
 
-	if len(args) < 1 {
+	var config configFlags
+	config.register(cmdFlags)
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if cmdFlags.NArg() < 1 {
 		err := errors.New("A name for the migration is needed")
 		ui.Error(err.Error())
 		return 1
 	}
-
-	if err := cmdFlags.Parse(args); err != nil {
+	// Parsing stops at the name, the flags following it are parsed too.
+	name := cmdFlags.Arg(0)
+	if err := cmdFlags.Parse(cmdFlags.Args()[1:]); err != nil {
+		return 1
+	}
+	if cmdFlags.NArg() > 0 {
+		ui.Error(fmt.Sprintf("Unexpected arguments after the name: %s", strings.Join(cmdFlags.Args(), " ")))
+		return 1
+	}
+	if err := config.configure(c.migrate); err != nil {
+		ui.Error(err.Error())
 		return 1
 	}
 
-	if err := c.migrate.Create(cmdFlags.Arg(0)); err != nil {
+	if templatesDir != "" {
+		c.migrate.TemplatesDir = templatesDir
+	}
+	if naming != "" {
+		c.migrate.Naming = naming
+	}
+	if padding > 0 {
+		c.migrate.SequencePadding = padding
+	}
+
+	var err error
+	if columns != "" {
+		err = c.migrate.CreateWithColumns(name, columns)
+	} else if templateName != "" {
+		err = c.migrate.CreateFromTemplate(name, templateName, vars)
+	} else {
+		err = c.migrate.Create(name)
+	}
+	if err != nil {
 		ui.Error(err.Error())
 		return 1
 	}
@@ -0,0 +1,74 @@
+package migration
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+type PlanCommand struct {
+	migrate *Migrate
+}
+
+func (c *PlanCommand) Help() string {
+	helpText := `
+Usage: %s plan [options] ...
+
+  Show which migrations would be applied, and why.
+
+Options:
+
+  -config=dbconfig.yml   Configuration file to use.
+  -env="development"     Environment.
+  -dialect=""            Dialect, overriding the configuration file.
+  -dsn=""                Connection string, overriding the configuration file.
+  -dir=""                Migrations directory, overriding the configuration file.
+  -table=""              Migration table, overriding the configuration file.
+  -schema=""             Schema of the migration table, overriding the configuration file.
+  -down                  Plan a rollback instead of an upgrade.
+  -limit=0               Limit the number of migrations (0 = unlimited).
+  -format=text           Output format (text or json).
+
+`
+	return strings.TrimSpace(fmt.Sprintf(helpText, Cmd))
+}
+
+func (c *PlanCommand) Synopsis() string {
+	return "Show which migrations would be applied"
+}
+
+func (c *PlanCommand) Run(args []string) int {
+	var down bool
+	var limit int
+	var format string
+
+	cmdFlags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	cmdFlags.Usage = func() { ui.Output(c.Help()) }
+	cmdFlags.BoolVar(&down, "down", false, "Plan a rollback instead of an upgrade.")
+	cmdFlags.IntVar(&limit, "limit", 0, "Max number of migrations to plan.")
+	cmdFlags.StringVar(&format, "format", "text", "Output format (text or json).")
+
+	var config configFlags
+	config.register(cmdFlags)
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if err := config.connect(c.migrate); err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+
+	dir := Up
+	if down {
+		dir = Down
+	}
+
+	err := c.migrate.Plan(dir, limit, format)
+	if err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
@@ -20,7 +20,19 @@ Options:
 
   -config=dbconfig.yml   Configuration file to use.
   -env="development"     Environment.
+  -dialect=""            Dialect, overriding the configuration file.
+  -dsn=""                Connection string, overriding the configuration file.
+  -dir=""                Migrations directory, overriding the configuration file.
+  -table=""              Migration table, overriding the configuration file.
+  -schema=""             Schema of the migration table, overriding the configuration file.
   -dryrun                Don't apply migrations, just print them.
+  -allow-destructive     Apply migrations with destructive statements.
+  -non-transactional=fail
+                         Migrations with statements that can not run in a
+                         transaction but lack notransaction: fail, auto (run
+                         them without a transaction) or ignore.
+  -schema-dump-dir=""    Directory receiving schema.sql and schema.json once
+                         the migrations are applied.
 
 `
 	return strings.TrimSpace(fmt.Sprintf(helpText, Cmd))
@@ -32,15 +44,42 @@ func (c *RedoCommand) Synopsis() string {
 
 func (c *RedoCommand) Run(args []string) int {
 	var dryrun bool
+	var allowDestructive bool
+	var nonTransactional string
+	var schemaDumpDir string
 
 	cmdFlags := flag.NewFlagSet("redo", flag.ContinueOnError)
 	cmdFlags.Usage = func() { ui.Output(c.Help()) }
 	cmdFlags.BoolVar(&dryrun, "dryrun", false, "Don't apply migrations, just print them.")
+	cmdFlags.BoolVar(&allowDestructive, "allow-destructive", false, "Apply migrations with destructive statements.")
+	cmdFlags.StringVar(&nonTransactional, "non-transactional", "", "Policy for statements that can not run in a transaction.")
+	cmdFlags.StringVar(&schemaDumpDir, "schema-dump-dir", "", "Directory receiving schema.sql and schema.json.")
+
+	var config configFlags
+	config.register(cmdFlags)
 
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
 	}
-	err := Redo(c.migrate.Dir, c.migrate.Dialect, c.migrate.DB, dryrun)
+	if err := config.connect(c.migrate); err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+	if allowDestructive {
+		SetAllowDestructive(true)
+	}
+	if nonTransactional != "" {
+		policy, err := ParseNonTransactionalPolicy(nonTransactional)
+		if err != nil {
+			ui.Error(err.Error())
+			return 1
+		}
+		SetNonTransactionalPolicy(policy)
+	}
+	if schemaDumpDir != "" {
+		SetSchemaDumpDir(schemaDumpDir)
+	}
+	err := c.migrate.Redo(dryrun)
 	if err != nil {
 		return 1
 	}
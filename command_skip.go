@@ -20,7 +20,15 @@ Options:
 
   -config=dbconfig.yml   Configuration file to use.
   -env="development"     Environment.
+  -dialect=""            Dialect, overriding the configuration file.
+  -dsn=""                Connection string, overriding the configuration file.
+  -dir=""                Migrations directory, overriding the configuration file.
+  -table=""              Migration table, overriding the configuration file.
+  -schema=""             Schema of the migration table, overriding the configuration file.
   -limit=0               Limit the number of migrations (0 = unlimited).
+  -to=""                 Skip the pending migrations up to and including this one.
+  -only=""               Skip only this pending migration.
+  -dryrun                Don't mark migrations as applied, just list them.
 
 `
 	return strings.TrimSpace(fmt.Sprintf(helpText, Cmd))
@@ -32,17 +40,30 @@ func (c *SkipCommand) Synopsis() string {
 
 func (c *SkipCommand) Run(args []string) int {
 	var limit int
+	var to string
+	var only string
 	var dryrun bool
 
-	cmdFlags := flag.NewFlagSet("up", flag.ContinueOnError)
+	cmdFlags := flag.NewFlagSet("skip", flag.ContinueOnError)
 	cmdFlags.Usage = func() { ui.Output(c.Help()) }
 	cmdFlags.IntVar(&limit, "limit", 0, "Max number of migrations to skip.")
+	cmdFlags.StringVar(&to, "to", "", "Skip the pending migrations up to and including this one.")
+	cmdFlags.StringVar(&only, "only", "", "Skip only this pending migration.")
+	cmdFlags.BoolVar(&dryrun, "dryrun", false, "Don't mark migrations as applied, just list them.")
+
+	var config configFlags
+	config.register(cmdFlags)
 
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
 	}
+	if err := config.connect(c.migrate); err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
 
-	err := c.migrate.SkipMigration(c.migrate.Dialect, c.migrate.DB, Up, dryrun, limit)
+	selection := SkipSelection{Max: limit, To: to, Only: only}
+	err := c.migrate.SkipSelected(c.migrate.Dialect, c.migrate.DB, selection, dryrun)
 	if err != nil {
 		ui.Error(err.Error())
 		return 1
@@ -4,7 +4,6 @@ import (
 	"flag"
 	"fmt"
 	"strings"
-	"time"
 )
 
 type StatusCommand struct {
@@ -21,6 +20,16 @@ Options:
 
   -config=dbconfig.yml   Configuration file to use.
   -env="development"     Environment.
+  -dialect=""            Dialect, overriding the configuration file.
+  -dsn=""                Connection string, overriding the configuration file.
+  -dir=""                Migrations directory, overriding the configuration file.
+  -table=""              Migration table, overriding the configuration file.
+  -schema=""             Schema of the migration table, overriding the configuration file.
+  -tenants               Show the status of every configured tenant schema.
+  -format=table          Output format (table, json, yaml or markdown).
+  -pending               Only show the pending migrations.
+  -applied               Only show the applied migrations.
+  -since=""              Only show the migrations applied since this date.
 
 `
 	return strings.TrimSpace(fmt.Sprintf(helpText, Cmd))
@@ -31,21 +40,46 @@ func (c *StatusCommand) Synopsis() string {
 }
 
 func (c *StatusCommand) Run(args []string) int {
+	var tenants bool
+	var format string
+	var filter StatusFilter
+	var since string
+
 	cmdFlags := flag.NewFlagSet("status", flag.ContinueOnError)
 	cmdFlags.Usage = func() { ui.Output(c.Help()) }
+	cmdFlags.BoolVar(&tenants, "tenants", false, "Show the status of every configured tenant schema.")
+	cmdFlags.StringVar(&format, "format", "table", "Output format (table, json, yaml or markdown).")
+	cmdFlags.BoolVar(&filter.Pending, "pending", false, "Only show the pending migrations.")
+	cmdFlags.BoolVar(&filter.Applied, "applied", false, "Only show the applied migrations.")
+	cmdFlags.StringVar(&since, "since", "", "Only show the migrations applied since this date.")
+
+	var config configFlags
+	config.register(cmdFlags)
 
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
 	}
-	err := Status(c.migrate.Dir, c.migrate.Dialect, c.migrate.DB)
+	if err := config.connect(c.migrate); err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+	if tenants {
+		if err := c.migrate.TenantStatus(); err != nil {
+			return 1
+		}
+		return 0
+	}
+	if since != "" {
+		t, err := ParseSince(since)
+		if err != nil {
+			ui.Error(err.Error())
+			return 1
+		}
+		filter.Since = t
+	}
+	err := c.migrate.PrintStatus(filter, format)
 	if err != nil {
 		return 1
 	}
 	return 0
 }
-
-type statusRow struct {
-	Id        string
-	Migrated  bool
-	AppliedAt time.Time
-}
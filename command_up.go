@@ -20,8 +20,26 @@ Options:
 
   -config=dbconfig.yml   Configuration file to use.
   -env="development"     Environment.
+  -dialect=""            Dialect, overriding the configuration file.
+  -dsn=""                Connection string, overriding the configuration file.
+  -dir=""                Migrations directory, overriding the configuration file.
+  -table=""              Migration table, overriding the configuration file.
+  -schema=""             Schema of the migration table, overriding the configuration file.
   -limit=0               Limit the number of migrations (0 = unlimited).
   -dryrun                Don't apply migrations, just print them.
+  -allow-destructive     Apply migrations with destructive statements.
+  -non-transactional=fail
+                         Migrations with statements that can not run in a
+                         transaction but lack notransaction: fail, auto (run
+                         them without a transaction) or ignore.
+  -validate              Execute migrations in a transaction that is rolled back.
+  -tenants               Apply to every configured tenant schema.
+  -parallel=1            Number of tenants or targets migrated concurrently.
+  -continue-on-error     Keep migrating other tenants after a failure.
+  -targets               Apply to every configured target database.
+  -format=table          Report format for -targets (table or json).
+  -schema-dump-dir=""    Directory receiving schema.sql and schema.json once
+                         the migrations are applied.
 
 `
 	return strings.TrimSpace(fmt.Sprintf(helpText, Cmd))
@@ -34,17 +52,75 @@ func (c *UpCommand) Synopsis() string {
 func (c *UpCommand) Run(args []string) int {
 	var limit int
 	var dryrun bool
+	var allowDestructive bool
+	var nonTransactional string
+	var schemaDumpDir string
+	var validate bool
+	var tenants bool
+	var parallel int
+	var continueOnError bool
+	var targets bool
+	var format string
 
 	cmdFlags := flag.NewFlagSet("up", flag.ContinueOnError)
 	cmdFlags.Usage = func() { ui.Output(c.Help()) }
 	cmdFlags.IntVar(&limit, "limit", 0, "Max number of migrations to apply.")
 	cmdFlags.BoolVar(&dryrun, "dryrun", false, "Don't apply migrations, just print them.")
+	cmdFlags.BoolVar(&allowDestructive, "allow-destructive", false, "Apply migrations with destructive statements.")
+	cmdFlags.StringVar(&nonTransactional, "non-transactional", "", "Policy for statements that can not run in a transaction.")
+	cmdFlags.StringVar(&schemaDumpDir, "schema-dump-dir", "", "Directory receiving schema.sql and schema.json.")
+	cmdFlags.BoolVar(&validate, "validate", false, "Execute migrations in a transaction that is rolled back.")
+	cmdFlags.BoolVar(&tenants, "tenants", false, "Apply to every configured tenant schema.")
+	cmdFlags.IntVar(&parallel, "parallel", 1, "Number of tenants or targets migrated concurrently.")
+	cmdFlags.BoolVar(&continueOnError, "continue-on-error", false, "Keep migrating other tenants after a failure.")
+	cmdFlags.BoolVar(&targets, "targets", false, "Apply to every configured target database.")
+	cmdFlags.StringVar(&format, "format", "table", "Report format for -targets (table or json).")
+
+	var config configFlags
+	config.register(cmdFlags)
 
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
 	}
+	// The tenant and fan-out runners have no dry run, they would apply
+	// the migrations.
+	if dryrun && tenants {
+		ui.Error("-dryrun cannot be combined with -tenants")
+		return 1
+	}
+	if dryrun && targets {
+		ui.Error("-dryrun cannot be combined with -targets")
+		return 1
+	}
+	if err := config.connect(c.migrate); err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+	if allowDestructive {
+		SetAllowDestructive(true)
+	}
+	if nonTransactional != "" {
+		policy, err := ParseNonTransactionalPolicy(nonTransactional)
+		if err != nil {
+			ui.Error(err.Error())
+			return 1
+		}
+		SetNonTransactionalPolicy(policy)
+	}
+	if schemaDumpDir != "" {
+		SetSchemaDumpDir(schemaDumpDir)
+	}
 
-	err := c.migrate.Apply(Up, dryrun, limit)
+	var err error
+	if validate {
+		err = c.migrate.Validate(Up, limit)
+	} else if targets {
+		err = c.migrate.ApplyTargets(Up, limit, parallel, format)
+	} else if tenants {
+		err = c.migrate.ApplyTenants(Up, limit, parallel, continueOnError)
+	} else {
+		err = c.migrate.Apply(Up, dryrun, limit)
+	}
 	if err != nil {
 		ui.Error(err.Error())
 		return 1
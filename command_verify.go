@@ -0,0 +1,71 @@
+package migration
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+type VerifyReversibleCommand struct {
+	migrate *Migrate
+}
+
+func (c *VerifyReversibleCommand) Help() string {
+	helpText := `
+Usage: %s verify-reversible [options] ...
+
+  Check that every Down reverses its Up, against a scratch database.
+
+  Each migration is applied up, rolled back and applied up again while the
+  schema is compared before and after. Never point -dsn at a database you
+  care about.
+
+Options:
+
+  -config=dbconfig.yml   Configuration file to use.
+  -env="development"     Environment.
+  -dir=""                Migrations directory, overriding the configuration file.
+  -table=""              Migration table, overriding the configuration file.
+  -schema=""             Schema of the migration table, overriding the configuration file.
+  -dialect=sqlite3       Dialect of the scratch database (default: configured dialect).
+  -dsn=""                Scratch database. Defaults to a temporary sqlite3 file.
+
+`
+	return strings.TrimSpace(fmt.Sprintf(helpText, Cmd))
+}
+
+func (c *VerifyReversibleCommand) Synopsis() string {
+	return "Check that every Down reverses its Up"
+}
+
+func (c *VerifyReversibleCommand) Run(args []string) int {
+	var dialect string
+	var dsn string
+
+	cmdFlags := flag.NewFlagSet("verify-reversible", flag.ContinueOnError)
+	cmdFlags.Usage = func() { ui.Output(c.Help()) }
+	cmdFlags.StringVar(&dialect, "dialect", "", "Dialect of the scratch database.")
+	cmdFlags.StringVar(&dsn, "dsn", "", "Scratch database.")
+
+	var config configFlags
+	config.register(cmdFlags)
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if err := config.configure(c.migrate); err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+
+	if dialect == "" {
+		dialect = c.migrate.Dialect
+	}
+	err := c.migrate.VerifyReversible(dialect, dsn)
+	if err != nil {
+		ui.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
@@ -2,8 +2,11 @@ package migration
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 )
@@ -12,56 +15,116 @@ func Status(dir, dialect string, db *sql.DB) error {
 	source := FileMigrationSource{
 		Dir: dir,
 	}
-	migrations, err := source.FindMigrations()
+	return PrintStatus(db, dialect, source, StatusFilter{}, "table")
+}
+
+// PrintStatus writes the filtered status of the migrations to os.Stdout in
+// the given format, warning about applied migrations missing from source.
+func PrintStatus(db *sql.DB, dialect string, source MigrationSource, filter StatusFilter, format string) error {
+	report, err := GetStatus(db, dialect, source)
 	if err != nil {
 		ui.Error(err.Error())
 		return err
 	}
-
-	records, err := GetMigrationRecords(db, dialect)
-	if err != nil {
+	for _, id := range report.Unknown {
+		ui.Warn(fmt.Sprintf("Could not find migration file: %v", id))
+	}
+	if err := report.Filter(filter).Write(os.Stdout, format); err != nil {
 		ui.Error(err.Error())
 		return err
 	}
+	return nil
+}
 
+// PrintTenantResults renders the outcome of a tenant run and returns an error
+// when at least one tenant failed.
+func PrintTenantResults(results []*TenantResult) error {
 	table := tablewriter.NewWriter(os.Stdout)
-	table.Header([]string{"Migration", "Applied"})
+	table.Header([]string{"Tenant", "Applied", "Duration", "Result"})
+
+	failed := 0
+	for _, r := range results {
+		result := "ok"
+		switch {
+		case r.Skipped:
+			result = "skipped"
+		case r.Err != nil:
+			result = r.Err.Error()
+			failed++
+		}
+		table.Append([]string{
+			r.Tenant,
+			strconv.Itoa(r.Applied),
+			r.Duration.Round(time.Millisecond).String(),
+			result,
+		})
+	}
 
-	rows := make(map[string]*statusRow)
+	table.Render()
 
-	for _, m := range migrations {
-		rows[m.Id] = &statusRow{
-			Id:       m.Id,
-			Migrated: false,
-		}
+	if failed > 0 {
+		return fmt.Errorf("Migration failed for %d of %d tenants", failed, len(results))
 	}
+	return nil
+}
+
+// PrintTenantStatus renders the tenant x migration state matrix.
+func PrintTenantStatus(statuses []*TenantStatus) error {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header([]string{"Tenant", "Applied", "Pending", "Unknown", "Latest"})
 
-	for _, r := range records {
-		if rows[r.Id] == nil {
-			ui.Warn(fmt.Sprintf("Could not find migration file: %v", r.Id))
+	failed := 0
+	for _, s := range statuses {
+		if s.Err != nil {
+			failed++
+			table.Append([]string{s.Tenant, "-", "-", "-", s.Err.Error()})
 			continue
 		}
+		table.Append([]string{
+			s.Tenant,
+			strconv.Itoa(s.Applied),
+			strconv.Itoa(s.Pending),
+			strconv.Itoa(s.Unknown),
+			s.Latest,
+		})
+	}
+
+	table.Render()
 
-		rows[r.Id].Migrated = true
-		rows[r.Id].AppliedAt = r.AppliedAt
+	if failed > 0 {
+		return fmt.Errorf("Could not read status of %d of %d tenants", failed, len(statuses))
 	}
+	return nil
+}
 
-	for _, m := range migrations {
-		if rows[m.Id] != nil && rows[m.Id].Migrated {
-			table.Append([]string{
-				m.Id,
-				rows[m.Id].AppliedAt.String(),
-			})
-		} else {
-			table.Append([]string{
-				m.Id,
-				"no",
-			})
-		}
+// PrintFanoutReport renders a fan-out report as a table or as JSON.
+func PrintFanoutReport(report *FanoutReport, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "", "table":
+	default:
+		return fmt.Errorf("Unknown format: %s", format)
 	}
 
-	table.Render()
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header([]string{"Target", "Dialect", "Applied", "Skipped", "Failed", "Duration", "Error"})
+
+	for _, t := range report.Targets {
+		table.Append([]string{
+			t.Target,
+			t.Dialect,
+			strconv.Itoa(len(t.Applied)),
+			strconv.Itoa(len(t.Skipped)),
+			t.Failed,
+			t.Duration.Round(time.Millisecond).String(),
+			t.Error,
+		})
+	}
 
+	table.Render()
 	return nil
 }
 
@@ -69,7 +132,12 @@ func Redo(dir, dialect string, db *sql.DB, dryRun bool) error {
 	source := FileMigrationSource{
 		Dir: dir,
 	}
+	return RedoMigration(db, dialect, source, dryRun)
+}
 
+// RedoMigration rolls back the last applied migration of source and applies
+// it again.
+func RedoMigration(db *sql.DB, dialect string, source MigrationSource, dryRun bool) error {
 	migrations, _, err := PlanMigration(db, dialect, source, Down, 1)
 	if err != nil {
 		ui.Error(fmt.Sprintf("Migration (redo) failed: %v", err))
@@ -101,8 +169,15 @@ func Redo(dir, dialect string, db *sql.DB, dryRun bool) error {
 	return nil
 }
 
+// Print outputs the statements of the migration in the given direction. When
+// dir matches the planned direction, the planned queries and reason are used.
 func Print(pm *PlannedMigration, dir MigrationDirection) {
-	if dir == Up {
+	if dir == pm.Direction && pm.Reason != "" {
+		ui.Output(fmt.Sprintf("==> Would apply migration %s (%s, %s)", pm.Id, dir, pm.Reason))
+		for _, q := range pm.Queries {
+			ui.Output(q)
+		}
+	} else if dir == Up {
 		ui.Output(fmt.Sprintf("==> Would apply migration %s (up)", pm.Id))
 		for _, q := range pm.Up {
 			ui.Output(q)
@@ -3,18 +3,56 @@ package migration
 import (
 	"database/sql"
 	"embed"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
 
-	"gopkg.in/gorp.v1"
+// dialectDrivers maps every dialect to the database/sql drivers it can use,
+// in order of preference. Which drivers are linked in depends on build tags:
+// nosqlite3, nopostgresql and nomysql leave a driver out, purego (or
+// CGO_ENABLED=0) swaps github.com/mattn/go-sqlite3 for modernc.org/sqlite.
+// The duckdb driver, github.com/duckdb/duckdb-go/v2, is only linked in with
+// the duckdb tag.
+var dialectDrivers = map[string][]string{
+	"sqlite3":    {"sqlite3", "sqlite"},
+	"postgresql": {"postgres", "pgx"},
+	"mysql":      {"mysql"},
+	"duckdb":     {"duckdb"},
+}
 
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/lib/pq"
-	_ "github.com/mattn/go-sqlite3"
-)
+// DialectAliases maps alternative dialect names, such as driver names, onto
+// the keys of MigrationDialects.
+var DialectAliases = map[string]string{
+	"sqlite":   "sqlite3",
+	"postgres": "postgresql",
+	"pgx":      "postgresql",
+	"mariadb":  "mysql",
+}
+
+// CanonicalDialect resolves a dialect alias. Other names are returned as is.
+func CanonicalDialect(dialect string) string {
+	if canonical, ok := DialectAliases[dialect]; ok {
+		return canonical
+	}
+	return dialect
+}
 
-var dialects = map[string]gorp.Dialect{
-	"sqlite3":    gorp.SqliteDialect{},
-	"postgresql": gorp.PostgresDialect{},
-	"mysql":      gorp.MySQLDialect{Engine: "InnoDB", Encoding: "UTF8"},
+// dialectDriver returns the name of a registered database/sql driver for the
+// dialect.
+func dialectDriver(dialect string) (string, error) {
+	drivers, ok := dialectDrivers[CanonicalDialect(dialect)]
+	if !ok {
+		return "", fmt.Errorf("Unknown dialect: %s", dialect)
+	}
+	registered := sql.Drivers()
+	for _, driver := range drivers {
+		if slices.Contains(registered, driver) {
+			return driver, nil
+		}
+	}
+	return "", fmt.Errorf("No driver for dialect %s is linked in, build without the no%s tag or import one of: %s", dialect, CanonicalDialect(dialect), strings.Join(drivers, ", "))
 }
 
 type Config struct {
@@ -26,10 +64,45 @@ type Config struct {
 	Dir        string `yaml:"directory"`
 	TableName  string `yaml:"table"`
 	Dialect    string `yaml:"dialect"`
+	// SchemaName schema of the migration table.
+	SchemaName string `yaml:"schema"`
+	// Tenants and TenantQuery list the postgresql schemas targeted by the
+	// -tenants flag of up, down and status.
+	Tenants     []string `yaml:"tenants"`
+	TenantQuery string   `yaml:"tenant_query"`
+	// AllowDestructive applies migrations with destructive statements even
+	// without a '-- +migrate Destructive' header.
+	AllowDestructive bool `yaml:"allow_destructive"`
+	// SchemaDumpDir receives schema.sql and schema.json after migrations are
+	// applied. Disabled when empty.
+	SchemaDumpDir string `yaml:"schema_dump"`
+	// FileNamePattern regular expression migration file names are linted
+	// against. Defaults to DefaultFileNamePattern.
+	FileNamePattern string `yaml:"file_name_pattern"`
+	// ConnectTimeout is how long connections opened from a -dsn or a
+	// configuration file are retried while the database starts up.
+	// Disabled when zero.
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	// MigrationSource, when set, provides the migrations instead of Dir
+	// or EmbeddedFS.
+	MigrationSource MigrationSource `yaml:"-"`
+	// TemplatesDir holds the name.sql.tmpl templates of the new command.
+	// Defaults to ./database/templates.
+	TemplatesDir string `yaml:"templates"`
+	// Naming is the naming strategy of new migrations, NamingTimestamp
+	// (default) or NamingSequential, numbers being padded to
+	// SequencePadding digits (default 4). NamingFunc, when set, replaces
+	// the strategy.
+	Naming          string     `yaml:"naming"`
+	SequencePadding int        `yaml:"sequence_padding"`
+	NamingFunc      NamingFunc `yaml:"-"`
+	// Targets lists the databases migrated by the -targets flag of up and down.
+	Targets []Target `yaml:"-"`
 }
 
 var (
-	defaultDir       = "./database/migrations"
-	defaultDialect   = "postgresql"
-	defaultTableName = "migrations"
+	defaultDir          = "./database/migrations"
+	defaultDialect      = "postgresql"
+	defaultTableName    = "migrations"
+	defaultTemplatesDir = "./database/templates"
 )
@@ -0,0 +1,230 @@
+package migration
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	GraphFormatDot     = "dot"
+	GraphFormatMermaid = "mermaid"
+)
+
+// indexMigrations maps every migration by its Id and by its Id without the
+// .sql extension, so DependsOn headers may reference either form.
+func indexMigrations(migrations []*Migration) map[string]*Migration {
+	index := make(map[string]*Migration, len(migrations)*2)
+	for _, m := range migrations {
+		index[m.Id] = m
+		index[strings.TrimSuffix(m.Id, ".sql")] = m
+	}
+	return index
+}
+
+// hasDependencies reports whether any migration declares a DependsOn header.
+func hasDependencies(migrations []*Migration) bool {
+	for _, m := range migrations {
+		if len(m.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// validateDependencies makes sure every declared parent exists and that the
+// dependency graph contains no cycles.
+func validateDependencies(migrations []*Migration) error {
+	if !hasDependencies(migrations) {
+		return nil
+	}
+	_, err := SortByDependencies(migrations)
+	return err
+}
+
+// SortByDependencies returns the migrations in topological order: every
+// migration comes after all of its parents. Independent migrations keep the
+// regular Id order, so a set without DependsOn headers is returned unchanged.
+func SortByDependencies(migrations []*Migration) ([]*Migration, error) {
+	index := indexMigrations(migrations)
+
+	parents := make(map[*Migration][]*Migration, len(migrations))
+	children := make(map[*Migration][]*Migration, len(migrations))
+	for _, m := range migrations {
+		for _, dep := range m.DependsOn {
+			parent, ok := index[dep]
+			if !ok {
+				return nil, fmt.Errorf("Migration %s depends on unknown migration %s", m.Id, dep)
+			}
+			parents[m] = append(parents[m], parent)
+			children[parent] = append(children[parent], m)
+		}
+	}
+
+	pending := make(map[*Migration]int, len(migrations))
+	var ready []*Migration
+	for _, m := range migrations {
+		pending[m] = len(parents[m])
+		if pending[m] == 0 {
+			ready = append(ready, m)
+		}
+	}
+
+	sorted := make([]*Migration, 0, len(migrations))
+	for len(ready) > 0 {
+		sort.Sort(byId(ready))
+		next := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, next)
+		for _, child := range children[next] {
+			pending[child]--
+			if pending[child] == 0 {
+				ready = append(ready, child)
+			}
+		}
+	}
+
+	if len(sorted) != len(migrations) {
+		return nil, fmt.Errorf("Dependency cycle detected: %s", strings.Join(findCycle(migrations, parents, pending), " -> "))
+	}
+	return sorted, nil
+}
+
+// findCycle walks the migrations left unsorted by SortByDependencies and
+// returns the ids along one cycle, repeating the first id at the end.
+func findCycle(migrations []*Migration, parents map[*Migration][]*Migration, pending map[*Migration]int) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[*Migration]int, len(migrations))
+	var stack []*Migration
+	var cycle []string
+
+	var visit func(*Migration) bool
+	visit = func(m *Migration) bool {
+		state[m] = visiting
+		stack = append(stack, m)
+		for _, parent := range parents[m] {
+			switch state[parent] {
+			case visiting:
+				for i, s := range stack {
+					if s == parent {
+						for _, c := range stack[i:] {
+							cycle = append(cycle, c.Id)
+						}
+						cycle = append(cycle, parent.Id)
+						return true
+					}
+				}
+			case unvisited:
+				if visit(parent) {
+					return true
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[m] = done
+		return false
+	}
+
+	for _, m := range migrations {
+		if pending[m] > 0 && state[m] == unvisited && visit(m) {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// planDependencies plans migrations following the dependency graph. Up
+// applies every unapplied migration in topological order, Down rolls back
+// applied migrations in reverse topological order.
+func planDependencies(migrations []*Migration, applied map[string]bool, dir MigrationDirection, max int) ([]*PlannedMigration, error) {
+	sorted, err := SortByDependencies(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*PlannedMigration, 0)
+	switch dir {
+	case Up:
+		for _, m := range sorted {
+			if applied[m.Id] {
+				continue
+			}
+			result = append(result, &PlannedMigration{
+				Migration:          m,
+				Queries:            m.Up,
+				DisableTransaction: m.DisableTransactionUp,
+				Direction:          Up,
+				Reason:             ReasonPending,
+			})
+		}
+	case Down:
+		for i := len(sorted) - 1; i >= 0; i-- {
+			m := sorted[i]
+			if !applied[m.Id] {
+				continue
+			}
+			result = append(result, &PlannedMigration{
+				Migration:          m,
+				Queries:            m.Down,
+				DisableTransaction: m.DisableTransactionDown,
+				Direction:          Down,
+				Reason:             ReasonRollback,
+			})
+		}
+	default:
+		panic("Not possible")
+	}
+
+	if max > 0 && max < len(result) {
+		result = result[:max]
+	}
+	return result, nil
+}
+
+// WriteGraph renders the migration dependency graph in the given format
+// (GraphFormatDot or GraphFormatMermaid). Edges point from parent to child.
+func WriteGraph(w io.Writer, migrations []*Migration, format string) error {
+	sorted, err := SortByDependencies(migrations)
+	if err != nil {
+		return err
+	}
+	index := indexMigrations(sorted)
+
+	var b strings.Builder
+	switch format {
+	case GraphFormatDot:
+		b.WriteString("digraph migrations {\n")
+		b.WriteString("\trankdir=LR;\n")
+		for _, m := range sorted {
+			fmt.Fprintf(&b, "\t%q;\n", m.Id)
+		}
+		for _, m := range sorted {
+			for _, dep := range m.DependsOn {
+				fmt.Fprintf(&b, "\t%q -> %q;\n", index[dep].Id, m.Id)
+			}
+		}
+		b.WriteString("}\n")
+	case GraphFormatMermaid:
+		nodes := make(map[string]string, len(sorted))
+		b.WriteString("graph TD\n")
+		for i, m := range sorted {
+			nodes[m.Id] = fmt.Sprintf("m%d", i)
+			fmt.Fprintf(&b, "\t%s[\"%s\"]\n", nodes[m.Id], strings.ReplaceAll(m.Id, `"`, "#quot;"))
+		}
+		for _, m := range sorted {
+			for _, dep := range m.DependsOn {
+				fmt.Fprintf(&b, "\t%s --> %s\n", nodes[index[dep].Id], nodes[m.Id])
+			}
+		}
+	default:
+		return fmt.Errorf("Unknown graph format: %s", format)
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,166 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+)
+
+func migrationIds(migrations []*Migration) string {
+	ids := make([]string, len(migrations))
+	for i, m := range migrations {
+		ids[i] = m.Id
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestSortByDependencies(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations []*Migration
+		want       string
+		err        string
+	}{
+		{
+			name:       "no dependencies",
+			migrations: []*Migration{{Id: "1_a.sql"}, {Id: "2_b.sql"}, {Id: "3_c.sql"}},
+			want:       "1_a.sql 2_b.sql 3_c.sql",
+		},
+		{
+			name: "parent after child id",
+			migrations: []*Migration{
+				{Id: "1_a.sql"},
+				{Id: "2_b.sql", DependsOn: []string{"3_c.sql"}},
+				{Id: "3_c.sql"},
+			},
+			want: "1_a.sql 3_c.sql 2_b.sql",
+		},
+		{
+			name: "parent without extension",
+			migrations: []*Migration{
+				{Id: "1_a.sql", DependsOn: []string{"2_b"}},
+				{Id: "2_b.sql"},
+			},
+			want: "2_b.sql 1_a.sql",
+		},
+		{
+			name: "diamond",
+			migrations: []*Migration{
+				{Id: "1_root.sql"},
+				{Id: "2_left.sql", DependsOn: []string{"1_root"}},
+				{Id: "3_right.sql", DependsOn: []string{"1_root"}},
+				{Id: "4_join.sql", DependsOn: []string{"3_right", "2_left"}},
+			},
+			want: "1_root.sql 2_left.sql 3_right.sql 4_join.sql",
+		},
+		{
+			name: "unknown parent",
+			migrations: []*Migration{
+				{Id: "1_a.sql", DependsOn: []string{"0_missing"}},
+			},
+			err: "Migration 1_a.sql depends on unknown migration 0_missing",
+		},
+		{
+			name: "cycle",
+			migrations: []*Migration{
+				{Id: "1_a.sql"},
+				{Id: "2_b.sql", DependsOn: []string{"3_c"}},
+				{Id: "3_c.sql", DependsOn: []string{"2_b"}},
+			},
+			err: "Dependency cycle detected: 2_b.sql -> 3_c.sql -> 2_b.sql",
+		},
+		{
+			name: "self dependency",
+			migrations: []*Migration{
+				{Id: "1_a.sql", DependsOn: []string{"1_a"}},
+			},
+			err: "Dependency cycle detected: 1_a.sql -> 1_a.sql",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sorted, err := SortByDependencies(test.migrations)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got error %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := migrationIds(sorted); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlanDependencies(t *testing.T) {
+	migrations := []*Migration{
+		{Id: "1_a.sql"},
+		{Id: "2_b.sql", DependsOn: []string{"3_c"}},
+		{Id: "3_c.sql"},
+		{Id: "4_d.sql", DependsOn: []string{"1_a"}},
+	}
+	tests := []struct {
+		name    string
+		applied []string
+		dir     MigrationDirection
+		max     int
+		want    string
+	}{
+		{"up from scratch", nil, Up, 0, "1_a.sql 3_c.sql 2_b.sql 4_d.sql"},
+		{"up with max", nil, Up, 2, "1_a.sql 3_c.sql"},
+		{"up skips applied", []string{"1_a.sql", "4_d.sql"}, Up, 0, "3_c.sql 2_b.sql"},
+		{"down in reverse order", []string{"1_a.sql", "2_b.sql", "3_c.sql"}, Down, 0, "2_b.sql 3_c.sql 1_a.sql"},
+		{"down with max", []string{"1_a.sql", "2_b.sql", "3_c.sql", "4_d.sql"}, Down, 1, "4_d.sql"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			applied := make(map[string]bool)
+			for _, id := range test.applied {
+				applied[id] = true
+			}
+			planned, err := planDependencies(migrations, applied, test.dir, test.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, len(planned))
+			for i, pm := range planned {
+				ids[i] = pm.Id
+				if pm.Direction != test.dir {
+					t.Errorf("%s planned %s, want %s", pm.Id, pm.Direction, test.dir)
+				}
+			}
+			if got := strings.Join(ids, " "); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteGraph(t *testing.T) {
+	migrations := []*Migration{
+		{Id: "1_a.sql"},
+		{Id: "2_b.sql", DependsOn: []string{"1_a"}},
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{GraphFormatDot, "digraph migrations {\n\trankdir=LR;\n\t\"1_a.sql\";\n\t\"2_b.sql\";\n\t\"1_a.sql\" -> \"2_b.sql\";\n}\n"},
+		{GraphFormatMermaid, "graph TD\n\tm0[\"1_a.sql\"]\n\tm1[\"2_b.sql\"]\n\tm0 --> m1\n"},
+	}
+	for _, test := range tests {
+		var b strings.Builder
+		if err := WriteGraph(&b, migrations, test.format); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != test.want {
+			t.Errorf("WriteGraph(%s) = %q, want %q", test.format, b.String(), test.want)
+		}
+	}
+	if err := WriteGraph(&strings.Builder{}, migrations, "svg"); err == nil {
+		t.Error("WriteGraph(svg) succeeded")
+	}
+}
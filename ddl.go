@@ -0,0 +1,749 @@
+package migration
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// The DDL parser below understands enough of the CREATE, ALTER and DROP
+// statements of sqlite3, postgresql and mysql to build a Schema from a
+// schema file, or from the Up statements of existing migrations. Statements
+// it does not understand, such as INSERT, are ignored.
+
+var (
+	ddlCreateTableRegex = regexp.MustCompile(`(?is)^CREATE\s+(?:(?:GLOBAL\s+|LOCAL\s+)?(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([^\s(]+)\s*\((.*)\)[^)]*$`)
+	ddlCreateIndexRegex = regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(?:([^\s(]+)\s+)?ON\s+(?:ONLY\s+)?([^\s(]+)\s*(?:USING\s+\w+\s*)?(\(.*\))`)
+	ddlCreateViewRegex  = regexp.MustCompile(`(?is)^CREATE\s+(?:OR\s+REPLACE\s+)?(?:(?:TEMP|TEMPORARY)\s+)?VIEW\s+(?:IF\s+NOT\s+EXISTS\s+)?([^\s(]+)(?:\s*\([^)]*\))?\s+AS\s+(.*)$`)
+	ddlDropRegex        = regexp.MustCompile(`(?is)^DROP\s+(TABLE|VIEW|INDEX)\s+(?:CONCURRENTLY\s+)?(?:IF\s+EXISTS\s+)?(.*?)(?:\s+ON\s+(\S+))?(?:\s+(?:CASCADE|RESTRICT))?$`)
+	ddlAlterTableRegex  = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?(\S+)\s+(.*)$`)
+	ddlRenameTableRegex = regexp.MustCompile(`(?is)^RENAME\s+TABLE\s+(\S+)\s+TO\s+(\S+)$`)
+)
+
+// columnKeywords end the type of a column definition.
+var columnKeywords = map[string]bool{
+	"NOT":            true,
+	"NULL":           true,
+	"DEFAULT":        true,
+	"PRIMARY":        true,
+	"UNIQUE":         true,
+	"REFERENCES":     true,
+	"CHECK":          true,
+	"CONSTRAINT":     true,
+	"AUTO_INCREMENT": true,
+	"AUTOINCREMENT":  true,
+	"COLLATE":        true,
+	"GENERATED":      true,
+	"COMMENT":        true,
+	"ON":             true,
+	"FIRST":          true,
+	"AFTER":          true,
+}
+
+// ParseSchema builds a Schema from a file of CREATE statements, as written
+// by hand or by Schema.WriteSQL.
+func ParseSchema(r io.Reader) (*Schema, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{}
+	for _, stmt := range splitTopLevel(stripSQLComments(string(content)), ';') {
+		if err := s.ApplyStatement(stmt); err != nil {
+			return nil, err
+		}
+	}
+	s.sort()
+	return s, nil
+}
+
+// ReplaySchema builds the Schema implied by the Up statements of every
+// migration of the source, without touching a database.
+func ReplaySchema(m MigrationSource) (*Schema, error) {
+	migrations, err := m.FindMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if hasDependencies(migrations) {
+		if migrations, err = SortByDependencies(migrations); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Schema{}
+	for _, migration := range migrations {
+		for _, stmt := range migration.Up {
+			if err := s.ApplyStatement(stripSQLComments(stmt)); err != nil {
+				return nil, fmt.Errorf("%s: %s", migration.Id, err)
+			}
+		}
+	}
+	s.sort()
+	return s, nil
+}
+
+// ApplyStatement updates the schema with the effect of a single DDL
+// statement. Statements that do not change the schema are ignored.
+func (s *Schema) ApplyStatement(stmt string) error {
+	stmt = strings.TrimSuffix(strings.TrimSpace(stmt), ";")
+	if stmt == "" {
+		return nil
+	}
+
+	if match := ddlCreateTableRegex.FindStringSubmatch(stmt); match != nil {
+		name := unquoteIdentifier(match[1])
+		if s.Table(name) != nil {
+			// CREATE TABLE IF NOT EXISTS on an existing table.
+			return nil
+		}
+		t := s.table(name)
+		for _, def := range splitTopLevel(match[2], ',') {
+			if err := t.addDefinition(sqlTokens(def)); err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+		}
+		return nil
+	}
+
+	if match := ddlCreateIndexRegex.FindStringSubmatch(stmt); match != nil {
+		t := s.Table(unquoteIdentifier(match[3]))
+		if t == nil {
+			return fmt.Errorf("Index on unknown table %s", match[3])
+		}
+		columns := splitColumnList(match[4])
+		name := unquoteIdentifier(match[2])
+		if name == "" {
+			name = t.Name + "_" + strings.Join(columns, "_") + "_idx"
+		}
+		t.dropIndex(name)
+		t.Indexes = append(t.Indexes, &SchemaIndex{Name: name, Columns: columns, Unique: match[1] != ""})
+		return nil
+	}
+
+	if match := ddlCreateViewRegex.FindStringSubmatch(stmt); match != nil {
+		name := unquoteIdentifier(match[1])
+		s.dropView(name)
+		s.Views = append(s.Views, &SchemaView{Name: name, Definition: strings.TrimSpace(match[2])})
+		return nil
+	}
+
+	if match := ddlDropRegex.FindStringSubmatch(stmt); match != nil {
+		for _, name := range splitColumnList(match[2]) {
+			switch strings.ToUpper(match[1]) {
+			case "TABLE":
+				s.dropTable(name)
+			case "VIEW":
+				s.dropView(name)
+			case "INDEX":
+				for _, t := range s.Tables {
+					t.dropIndex(name)
+				}
+			}
+		}
+		return nil
+	}
+
+	if match := ddlRenameTableRegex.FindStringSubmatch(stmt); match != nil {
+		if t := s.Table(unquoteIdentifier(match[1])); t != nil {
+			t.Name = unquoteIdentifier(match[2])
+		}
+		return nil
+	}
+
+	if match := ddlAlterTableRegex.FindStringSubmatch(stmt); match != nil {
+		t := s.Table(unquoteIdentifier(match[1]))
+		if t == nil {
+			return fmt.Errorf("ALTER TABLE on unknown table %s", match[1])
+		}
+		for _, action := range splitTopLevel(match[2], ',') {
+			if err := t.alter(sqlTokens(action)); err != nil {
+				return fmt.Errorf("%s: %s", t.Name, err)
+			}
+		}
+		return nil
+	}
+
+	return nil
+}
+
+func (s *Schema) dropTable(name string) {
+	for i, t := range s.Tables {
+		if t.Name == name {
+			s.Tables = append(s.Tables[:i], s.Tables[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Schema) dropView(name string) {
+	for i, v := range s.Views {
+		if v.Name == name {
+			s.Views = append(s.Views[:i], s.Views[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *SchemaTable) dropIndex(name string) {
+	for i, index := range t.Indexes {
+		if index.Name == name {
+			t.Indexes = append(t.Indexes[:i], t.Indexes[i+1:]...)
+			return
+		}
+	}
+	// mysql reports unique keys as constraints.
+	t.dropConstraint(name)
+}
+
+func (t *SchemaTable) dropColumn(name string) {
+	for i, c := range t.Columns {
+		if c.Name == name {
+			t.Columns = append(t.Columns[:i], t.Columns[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *SchemaTable) dropConstraint(name string) {
+	for i, c := range t.Constraints {
+		if c.Name == name {
+			t.Constraints = append(t.Constraints[:i], t.Constraints[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *SchemaTable) dropConstraintType(kind string) {
+	for i, c := range t.Constraints {
+		if c.Type == kind {
+			t.Constraints = append(t.Constraints[:i], t.Constraints[i+1:]...)
+			return
+		}
+	}
+}
+
+// addDefinition adds a column or table constraint from a CREATE TABLE.
+func (t *SchemaTable) addDefinition(tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	switch strings.ToUpper(tokens[0]) {
+	case "CONSTRAINT", "PRIMARY", "UNIQUE", "FOREIGN", "CHECK", "KEY", "INDEX", "FULLTEXT", "SPATIAL":
+		return t.addTableConstraint(tokens)
+	}
+	column, constraints := parseColumnDefinition(tokens)
+	if column == nil {
+		return fmt.Errorf("Cannot parse column definition: %s", strings.Join(tokens, " "))
+	}
+	t.dropColumn(column.Name)
+	t.Columns = append(t.Columns, column)
+	t.Constraints = append(t.Constraints, constraints...)
+	return nil
+}
+
+func (t *SchemaTable) addTableConstraint(tokens []string) error {
+	name := ""
+	if strings.EqualFold(tokens[0], "CONSTRAINT") && len(tokens) > 2 {
+		name = unquoteIdentifier(tokens[1])
+		tokens = tokens[2:]
+	}
+
+	keyword := strings.ToUpper(tokens[0])
+	switch keyword {
+	case "KEY", "INDEX", "FULLTEXT", "SPATIAL":
+		// mysql inline index: KEY name (columns)
+		for i, token := range tokens[1:] {
+			if strings.HasPrefix(token, "(") {
+				indexName := ""
+				if i > 0 {
+					indexName = unquoteIdentifier(tokens[i])
+				}
+				columns := splitColumnList(token)
+				if indexName == "" {
+					indexName = columns[0]
+				}
+				t.Indexes = append(t.Indexes, &SchemaIndex{Name: indexName, Columns: columns})
+				return nil
+			}
+		}
+	case "PRIMARY", "UNIQUE":
+		for i, token := range tokens[1:] {
+			if !strings.HasPrefix(token, "(") {
+				continue
+			}
+			columns := splitColumnList(token)
+			kind := ConstraintPrimaryKey
+			if keyword == "UNIQUE" {
+				kind = ConstraintUnique
+				// mysql: UNIQUE [KEY|INDEX] name (columns)
+				if last := strings.ToUpper(tokens[i]); name == "" && i > 0 && last != "KEY" && last != "INDEX" && last != "UNIQUE" {
+					name = unquoteIdentifier(tokens[i])
+				}
+			}
+			for _, column := range columns {
+				if c := t.Column(column); c != nil && kind == ConstraintPrimaryKey {
+					c.Nullable = false
+				}
+			}
+			t.Constraints = append(t.Constraints, &SchemaConstraint{
+				Name:       name,
+				Type:       kind,
+				Definition: fmt.Sprintf("%s (%s)", kind, strings.Join(columns, ", ")),
+			})
+			return nil
+		}
+	case "FOREIGN":
+		for i, token := range tokens[1:] {
+			if strings.HasPrefix(token, "(") {
+				definition, ok := referencesDefinition(tokens[i+2:])
+				if !ok {
+					break
+				}
+				t.Constraints = append(t.Constraints, &SchemaConstraint{
+					Name:       name,
+					Type:       ConstraintForeignKey,
+					Definition: fmt.Sprintf("FOREIGN KEY (%s) %s", strings.Join(splitColumnList(token), ", "), definition),
+				})
+				return nil
+			}
+		}
+	case "CHECK":
+		if len(tokens) > 1 {
+			t.Constraints = append(t.Constraints, &SchemaConstraint{
+				Name:       name,
+				Type:       ConstraintCheck,
+				Definition: "CHECK " + joinTokens(tokens[1:]),
+			})
+			return nil
+		}
+	}
+	return fmt.Errorf("Cannot parse constraint: %s", strings.Join(tokens, " "))
+}
+
+// alter applies a single action of an ALTER TABLE.
+func (t *SchemaTable) alter(tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	upper := make([]string, len(tokens))
+	for i, token := range tokens {
+		upper[i] = strings.ToUpper(token)
+	}
+	// skip drops the given optional keywords.
+	skip := func(i int, words ...string) int {
+		for _, word := range words {
+			if i < len(upper) && upper[i] == word {
+				i++
+			}
+		}
+		return i
+	}
+
+	switch upper[0] {
+	case "ADD":
+		i := skip(1, "COLUMN")
+		if i < len(upper) && upper[i] == "IF" {
+			i = skip(i, "IF", "NOT", "EXISTS")
+		}
+		if i >= len(tokens) {
+			break
+		}
+		return t.addDefinition(tokens[i:])
+
+	case "DROP":
+		if len(upper) < 2 {
+			break
+		}
+		switch upper[1] {
+		case "CONSTRAINT", "FOREIGN", "INDEX", "KEY", "CHECK":
+			i := skip(1, "CONSTRAINT", "FOREIGN", "INDEX", "KEY", "CHECK")
+			i = skip(i, "IF", "EXISTS")
+			if i < len(tokens) {
+				t.dropConstraint(unquoteIdentifier(tokens[i]))
+				t.dropIndex(unquoteIdentifier(tokens[i]))
+				return nil
+			}
+		case "PRIMARY":
+			t.dropConstraintType(ConstraintPrimaryKey)
+			return nil
+		default:
+			i := skip(1, "COLUMN")
+			i = skip(i, "IF", "EXISTS")
+			if i < len(tokens) {
+				name := unquoteIdentifier(tokens[i])
+				t.dropColumn(name)
+				t.dropColumnReferences(name)
+				return nil
+			}
+		}
+
+	case "RENAME":
+		if len(upper) >= 3 && upper[1] == "TO" {
+			t.Name = unquoteIdentifier(tokens[2])
+			return nil
+		}
+		i := skip(1, "COLUMN")
+		if i+2 < len(tokens) && upper[i+1] == "TO" {
+			from, to := unquoteIdentifier(tokens[i]), unquoteIdentifier(tokens[i+2])
+			if c := t.Column(from); c != nil {
+				c.Name = to
+			}
+			t.renameColumnReferences(from, to)
+			return nil
+		}
+
+	case "ALTER":
+		i := skip(1, "COLUMN")
+		if i+1 >= len(tokens) {
+			break
+		}
+		c := t.Column(unquoteIdentifier(tokens[i]))
+		if c == nil {
+			return fmt.Errorf("Unknown column %s", tokens[i])
+		}
+		rest := strings.Join(upper[i+1:], " ")
+		switch {
+		case strings.HasPrefix(rest, "TYPE "), strings.HasPrefix(rest, "SET DATA TYPE "):
+			j := skip(i+1, "SET", "DATA", "TYPE")
+			end := j
+			for end < len(upper) && upper[end] != "USING" && upper[end] != "COLLATE" {
+				end++
+			}
+			c.Type = joinTokens(tokens[j:end])
+		case rest == "SET NOT NULL":
+			c.Nullable = false
+		case rest == "DROP NOT NULL":
+			c.Nullable = true
+		case strings.HasPrefix(rest, "SET DEFAULT "):
+			c.Default = joinTokens(tokens[i+3:])
+		case rest == "DROP DEFAULT":
+			c.Default = ""
+		}
+		return nil
+
+	case "MODIFY", "CHANGE":
+		i := skip(1, "COLUMN")
+		from := ""
+		if upper[0] == "CHANGE" && i < len(tokens) {
+			from = unquoteIdentifier(tokens[i])
+			i++
+		}
+		if i >= len(tokens) {
+			break
+		}
+		column, constraints := parseColumnDefinition(tokens[i:])
+		if column == nil {
+			break
+		}
+		if from == "" {
+			from = column.Name
+		}
+		for j, c := range t.Columns {
+			if c.Name == from {
+				t.Columns[j] = column
+			}
+		}
+		if from != column.Name {
+			t.renameColumnReferences(from, column.Name)
+		}
+		t.Constraints = append(t.Constraints, constraints...)
+		return nil
+
+	default:
+		// Table options such as OWNER TO or ENGINE=.
+		return nil
+	}
+	return fmt.Errorf("Cannot parse ALTER TABLE action: %s", strings.Join(tokens, " "))
+}
+
+// dropColumnReferences drops the indexes and constraints that covered a
+// dropped column.
+func (t *SchemaTable) dropColumnReferences(column string) {
+	indexes := t.Indexes[:0]
+	for _, index := range t.Indexes {
+		if !slices.Contains(index.Columns, column) {
+			indexes = append(indexes, index)
+		}
+	}
+	t.Indexes = indexes
+
+	constraints := t.Constraints[:0]
+	for _, c := range t.Constraints {
+		if !slices.Contains(constraintColumns(c), column) {
+			constraints = append(constraints, c)
+		}
+	}
+	t.Constraints = constraints
+}
+
+func (t *SchemaTable) renameColumnReferences(from, to string) {
+	for _, index := range t.Indexes {
+		for i, column := range index.Columns {
+			if column == from {
+				index.Columns[i] = to
+			}
+		}
+	}
+	for _, c := range t.Constraints {
+		columns := constraintColumns(c)
+		if slices.Contains(columns, from) {
+			renamed := make([]string, len(columns))
+			for i, column := range columns {
+				renamed[i] = column
+				if column == from {
+					renamed[i] = to
+				}
+			}
+			c.Definition = strings.Replace(c.Definition, "("+strings.Join(columns, ", ")+")", "("+strings.Join(renamed, ", ")+")", 1)
+		}
+	}
+}
+
+// constraintColumns returns the local columns of a primary key, unique or
+// foreign key constraint.
+func constraintColumns(c *SchemaConstraint) []string {
+	if c.Type == ConstraintCheck {
+		return nil
+	}
+	start := strings.Index(c.Definition, "(")
+	end := strings.Index(c.Definition, ")")
+	if start < 0 || end < start {
+		return nil
+	}
+	return splitColumnList(c.Definition[start : end+1])
+}
+
+// parseColumnDefinition parses 'name type [modifiers]' and returns the
+// column along with the constraints declared inline.
+func parseColumnDefinition(tokens []string) (*SchemaColumn, []*SchemaConstraint) {
+	if len(tokens) < 2 {
+		return nil, nil
+	}
+	column := &SchemaColumn{Name: unquoteIdentifier(tokens[0]), Nullable: true}
+
+	i := 1
+	for i < len(tokens) && !columnKeywords[strings.ToUpper(tokens[i])] {
+		i++
+	}
+	column.Type = joinTokens(tokens[1:i])
+
+	var constraints []*SchemaConstraint
+	name := ""
+	for i < len(tokens) {
+		keyword := strings.ToUpper(tokens[i])
+		i++
+		switch keyword {
+		case "CONSTRAINT":
+			if i < len(tokens) {
+				name = unquoteIdentifier(tokens[i])
+				i++
+			}
+			continue
+		case "NOT":
+			if i < len(tokens) && strings.EqualFold(tokens[i], "NULL") {
+				column.Nullable = false
+				i++
+			}
+		case "NULL":
+			column.Nullable = true
+		case "DEFAULT":
+			start := i
+			for i < len(tokens) && !columnKeywords[strings.ToUpper(tokens[i])] {
+				i++
+			}
+			column.Default = joinTokens(tokens[start:i])
+		case "PRIMARY":
+			if i < len(tokens) && strings.EqualFold(tokens[i], "KEY") {
+				i++
+			}
+			column.Nullable = false
+			constraints = append(constraints, &SchemaConstraint{
+				Name:       name,
+				Type:       ConstraintPrimaryKey,
+				Definition: fmt.Sprintf("PRIMARY KEY (%s)", column.Name),
+			})
+		case "UNIQUE":
+			if i < len(tokens) && strings.EqualFold(tokens[i], "KEY") {
+				i++
+			}
+			constraints = append(constraints, &SchemaConstraint{
+				Name:       name,
+				Type:       ConstraintUnique,
+				Definition: fmt.Sprintf("UNIQUE (%s)", column.Name),
+			})
+		case "REFERENCES":
+			start := i - 1
+			if i < len(tokens) {
+				i++
+			}
+			if i < len(tokens) && strings.HasPrefix(tokens[i], "(") {
+				i++
+			}
+			// ON DELETE|UPDATE CASCADE|RESTRICT|SET NULL|SET DEFAULT|NO ACTION
+			for i+1 < len(tokens) && strings.EqualFold(tokens[i], "ON") {
+				i += 2
+				if i < len(tokens) && (strings.EqualFold(tokens[i], "SET") || strings.EqualFold(tokens[i], "NO")) {
+					i++
+				}
+				if i < len(tokens) {
+					i++
+				}
+			}
+			if definition, ok := referencesDefinition(tokens[start:i]); ok {
+				constraints = append(constraints, &SchemaConstraint{
+					Name:       name,
+					Type:       ConstraintForeignKey,
+					Definition: fmt.Sprintf("FOREIGN KEY (%s) %s", column.Name, definition),
+				})
+			}
+		case "CHECK":
+			if i < len(tokens) {
+				constraints = append(constraints, &SchemaConstraint{
+					Name:       name,
+					Type:       ConstraintCheck,
+					Definition: "CHECK " + tokens[i],
+				})
+				i++
+			}
+		case "COLLATE", "COMMENT", "AFTER":
+			i++
+		case "GENERATED":
+			// GENERATED ... AS IDENTITY [(options)] or AS (expression) [STORED]
+			for i < len(tokens) && !columnKeywords[strings.ToUpper(tokens[i])] {
+				i++
+			}
+		}
+		name = ""
+	}
+	return column, constraints
+}
+
+// referencesDefinition normalizes 'REFERENCES table [(columns)] [ON ...]'.
+func referencesDefinition(tokens []string) (string, bool) {
+	if len(tokens) < 2 || !strings.EqualFold(tokens[0], "REFERENCES") {
+		return "", false
+	}
+	definition := "REFERENCES " + unquoteIdentifier(tokens[1])
+	rest := tokens[2:]
+	if len(rest) > 0 && strings.HasPrefix(rest[0], "(") {
+		definition += " (" + strings.Join(splitColumnList(rest[0]), ", ") + ")"
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		definition += " " + strings.ToUpper(strings.Join(rest, " "))
+	}
+	return definition, true
+}
+
+// sqlTokens splits a definition into words, quoted strings and
+// parenthesized groups. A group is always a token of its own.
+func sqlTokens(s string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			flush()
+		case r == '\'' || r == '"' || r == '`':
+			current.WriteRune(r)
+			for i++; i < len(runes); i++ {
+				current.WriteRune(runes[i])
+				if runes[i] == r {
+					break
+				}
+			}
+		case r == '(':
+			flush()
+			depth := 0
+			var quote rune
+			for ; i < len(runes); i++ {
+				c := runes[i]
+				current.WriteRune(c)
+				switch {
+				case quote != 0:
+					if c == quote {
+						quote = 0
+					}
+				case c == '\'' || c == '"' || c == '`':
+					quote = c
+				case c == '(':
+					depth++
+				case c == ')':
+					depth--
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// joinTokens joins tokens with spaces, attaching parenthesized groups to the
+// word they follow, e.g. "varchar(255)" or "now()".
+func joinTokens(tokens []string) string {
+	var b strings.Builder
+	for i, token := range tokens {
+		if i > 0 && !strings.HasPrefix(token, "(") {
+			b.WriteString(" ")
+		}
+		b.WriteString(token)
+	}
+	return b.String()
+}
+
+// splitColumnList turns "(a, "b")" or "a, b" into [a b].
+func splitColumnList(s string) []string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		s = s[1 : len(s)-1]
+	}
+	var columns []string
+	for _, part := range splitTopLevel(s, ',') {
+		fields := sqlTokens(part)
+		if len(fields) == 0 {
+			continue
+		}
+		column := unquoteIdentifier(fields[0])
+		// Keep expressions and prefix lengths, drop ASC/DESC.
+		if len(fields) > 1 && strings.HasPrefix(fields[1], "(") {
+			column += fields[1]
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// unquoteIdentifier strips quotes and the schema from an identifier.
+func unquoteIdentifier(s string) string {
+	if i := strings.LastIndex(s, "."); i >= 0 && !strings.ContainsAny(s[i:], `"`+"`") {
+		s = s[i+1:]
+	} else if i := strings.LastIndex(s, `".`); i >= 0 {
+		s = s[i+2:]
+	} else if i := strings.LastIndex(s, "`."); i >= 0 {
+		s = s[i+2:]
+	}
+	if len(s) >= 2 {
+		first, last := s[0], s[len(s)-1]
+		if (first == '"' && last == '"') || (first == '`' && last == '`') || (first == '[' && last == ']') {
+			s = s[1 : len(s)-1]
+		}
+	}
+	return s
+}
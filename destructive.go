@@ -0,0 +1,189 @@
+package migration
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	DestructiveDropTable  = "DROP TABLE"
+	DestructiveDropColumn = "DROP COLUMN"
+	DestructiveDropSchema = "DROP SCHEMA"
+	DestructiveTruncate   = "TRUNCATE"
+	DestructiveDeleteAll  = "DELETE without WHERE"
+	DestructiveAlterType  = "ALTER TYPE narrowing"
+)
+
+var (
+	dropTableRegex  = regexp.MustCompile(`(?is)^\s*DROP\s+TABLE\b`)
+	alterTableRegex = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\b`)
+	dropWordRegex   = regexp.MustCompile(`(?is)\bDROP\s+(\w+)`)
+	alterTypeRegex  = regexp.MustCompile(`(?is)\bALTER\s+(?:COLUMN\s+)?\S+\s+(?:SET\s+DATA\s+)?TYPE\s+(\w+(?:\s+(?:precision|varying))?(?:\s*\([^)]*\))?)`)
+	modifyRegex     = regexp.MustCompile(`(?is)\bMODIFY\s+(?:COLUMN\s+)?\S+\s+(\w+(?:\s*\([^)]*\))?)`)
+	changeRegex     = regexp.MustCompile(`(?is)\bCHANGE\s+(?:COLUMN\s+)?\S+\s+\S+\s+(\w+(?:\s*\([^)]*\))?)`)
+	dropSchemaRegex = regexp.MustCompile(`(?is)^\s*DROP\s+(SCHEMA|DATABASE)\b`)
+	truncateRegex   = regexp.MustCompile(`(?is)^\s*TRUNCATE\b`)
+	deleteRegex     = regexp.MustCompile(`(?is)^\s*DELETE\s+FROM\b`)
+	whereRegex      = regexp.MustCompile(`(?is)\bWHERE\b`)
+)
+
+// stripSQLComments removes '--' line comments so they do not influence the
+// classification of a statement.
+func stripSQLComments(stmt string) string {
+	lines := strings.Split(stmt, "\n")
+	for i, line := range lines {
+		if idx := strings.Index(line, "--"); idx >= 0 {
+			lines[i] = line[:idx]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DestructiveKinds classifies a statement and returns the kinds of
+// destructive operations it performs, if any.
+func DestructiveKinds(stmt string) []string {
+	stmt = stripSQLComments(stmt)
+	var kinds []string
+	switch {
+	case dropTableRegex.MatchString(stmt):
+		kinds = append(kinds, DestructiveDropTable)
+	case dropSchemaRegex.MatchString(stmt):
+		kinds = append(kinds, DestructiveDropSchema)
+	case truncateRegex.MatchString(stmt):
+		kinds = append(kinds, DestructiveTruncate)
+	case deleteRegex.MatchString(stmt) && !whereRegex.MatchString(stmt):
+		kinds = append(kinds, DestructiveDeleteAll)
+	case alterTableRegex.MatchString(stmt):
+		if dropsColumn(stmt) {
+			kinds = append(kinds, DestructiveDropColumn)
+		}
+		if narrowsType(stmt) {
+			kinds = append(kinds, DestructiveAlterType)
+		}
+	}
+	return kinds
+}
+
+// wideTypes are the column types a change to can not lose data, as they have
+// no length or precision limit.
+var wideTypes = map[string]bool{
+	"text":             true,
+	"mediumtext":       true,
+	"longtext":         true,
+	"varchar":          true,
+	"charactervarying": true,
+	"bigint":           true,
+	"int8":             true,
+	"numeric":          true,
+	"decimal":          true,
+	"doubleprecision":  true,
+	"double":           true,
+	"float8":           true,
+	"json":             true,
+	"jsonb":            true,
+	"bytea":            true,
+	"blob":             true,
+	"longblob":         true,
+}
+
+// narrowsType reports whether an ALTER TABLE statement changes a column to a
+// type that may not hold every existing value. Without the current schema at
+// hand, any change to a bounded type is considered narrowing.
+func narrowsType(stmt string) bool {
+	for _, re := range []*regexp.Regexp{alterTypeRegex, modifyRegex, changeRegex} {
+		for _, match := range re.FindAllStringSubmatch(stmt, -1) {
+			t := strings.ToLower(strings.Join(strings.Fields(match[1]), ""))
+			if strings.Contains(t, "(") || !wideTypes[t] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DestructiveStatement is a statement classified as destructive.
+type DestructiveStatement struct {
+	Statement string
+	Kinds     []string
+}
+
+// ClassifyStatements returns the destructive statements among stmts.
+func ClassifyStatements(stmts []string) []DestructiveStatement {
+	var result []DestructiveStatement
+	for _, stmt := range stmts {
+		if kinds := DestructiveKinds(stmt); len(kinds) > 0 {
+			result = append(result, DestructiveStatement{
+				Statement: stmt,
+				Kinds:     kinds,
+			})
+		}
+	}
+	return result
+}
+
+// DestructiveError is returned when a migration contains destructive
+// statements but neither the migration carries a '-- +migrate Destructive'
+// header nor the run allows destructive migrations.
+type DestructiveError struct {
+	Migration  *Migration
+	Direction  MigrationDirection
+	Statements []DestructiveStatement
+}
+
+func (e *DestructiveError) Error() string {
+	action := "apply"
+	if e.Direction == Down {
+		action = "roll back"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Refusing to %s %s: it contains destructive statements. Add '-- +migrate Destructive' to the file or pass -allow-destructive to proceed.", action, e.Migration.Id)
+	for _, s := range e.Statements {
+		fmt.Fprintf(&b, "\n  [%s] %s", strings.Join(s.Kinds, ", "), strings.TrimSpace(s.Statement))
+	}
+	return b.String()
+}
+
+// checkDestructive guards a planned migration against unacknowledged
+// destructive statements, in either direction: rolling back a migration
+// usually drops what it created, with the rows written since.
+func checkDestructive(pm *PlannedMigration) error {
+	if pm.AllowDestructive {
+		return nil
+	}
+	if statements := ClassifyStatements(pm.Queries); len(statements) > 0 {
+		return &DestructiveError{
+			Migration:  pm.Migration,
+			Direction:  pm.Direction,
+			Statements: statements,
+		}
+	}
+	return nil
+}
+
+// notColumnDrops are the words following DROP in an ALTER TABLE that remove
+// something other than a column.
+var notColumnDrops = map[string]bool{
+	"CONSTRAINT": true,
+	"INDEX":      true,
+	"KEY":        true,
+	"PRIMARY":    true,
+	"FOREIGN":    true,
+	"CHECK":      true,
+	"DEFAULT":    true,
+	"NOT":        true,
+	"IDENTITY":   true,
+	"EXPRESSION": true,
+	"PARTITION":  true,
+}
+
+// dropsColumn reports whether an ALTER TABLE statement drops a column, either
+// with DROP COLUMN or with the mysql shorthand DROP <column>.
+func dropsColumn(stmt string) bool {
+	for _, match := range dropWordRegex.FindAllStringSubmatch(stmt, -1) {
+		if !notColumnDrops[strings.ToUpper(match[1])] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package migration
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDestructiveKinds(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want []string
+	}{
+		{"CREATE TABLE users (id int)", nil},
+		{"DROP TABLE users", []string{DestructiveDropTable}},
+		{"drop table if exists users", []string{DestructiveDropTable}},
+		{"DROP INDEX users_name", nil},
+		{"DROP VIEW active_users", nil},
+		{"DROP SCHEMA tenant_a CASCADE", []string{DestructiveDropSchema}},
+		{"DROP DATABASE app", []string{DestructiveDropSchema}},
+		{"TRUNCATE users", []string{DestructiveTruncate}},
+		{"DELETE FROM users", []string{DestructiveDeleteAll}},
+		{"DELETE FROM users WHERE id = 1", nil},
+		{"UPDATE users SET name = ''", nil},
+		{"ALTER TABLE users DROP COLUMN email", []string{DestructiveDropColumn}},
+		{"ALTER TABLE users DROP email", []string{DestructiveDropColumn}},
+		{"ALTER TABLE users DROP CONSTRAINT users_email_key", nil},
+		{"ALTER TABLE users DROP INDEX users_email", nil},
+		{"ALTER TABLE users DROP PRIMARY KEY", nil},
+		{"ALTER TABLE users ALTER COLUMN email DROP NOT NULL", nil},
+		{"ALTER TABLE users ALTER COLUMN email DROP DEFAULT", nil},
+		{"ALTER TABLE users ADD COLUMN email text", nil},
+		{"ALTER TABLE users ALTER COLUMN name TYPE varchar(50)", []string{DestructiveAlterType}},
+		{"ALTER TABLE users ALTER COLUMN name SET DATA TYPE integer", []string{DestructiveAlterType}},
+		{"ALTER TABLE users ALTER COLUMN name TYPE text", nil},
+		{"ALTER TABLE users ALTER COLUMN price TYPE double precision", nil},
+		{"ALTER TABLE users MODIFY name varchar(20)", []string{DestructiveAlterType}},
+		{"ALTER TABLE users MODIFY COLUMN name longtext", nil},
+		{"ALTER TABLE users CHANGE name full_name varchar(20)", []string{DestructiveAlterType}},
+		{"ALTER TABLE users DROP COLUMN email, ALTER COLUMN name TYPE int", []string{DestructiveDropColumn, DestructiveAlterType}},
+		{"-- DROP TABLE users\nSELECT 1", nil},
+		{"DELETE FROM users -- WHERE id = 1", []string{DestructiveDeleteAll}},
+	}
+	for _, test := range tests {
+		got := DestructiveKinds(test.stmt)
+		if strings.Join(got, ", ") != strings.Join(test.want, ", ") {
+			t.Errorf("DestructiveKinds(%q) = %q, want %q", test.stmt, got, test.want)
+		}
+	}
+}
+
+func TestCheckDestructive(t *testing.T) {
+	drop := &Migration{Id: "2_drop_users.sql", Up: []string{"DROP TABLE users"}, Down: []string{"CREATE TABLE users (id int)"}}
+	create := &Migration{Id: "1_create_users.sql", Up: []string{"CREATE TABLE users (id int)"}, Down: []string{"DROP TABLE users"}}
+	acknowledged := &Migration{Id: "1_create_users.sql", Up: create.Up, Down: create.Down, AllowDestructive: true}
+
+	tests := []struct {
+		name      string
+		migration *Migration
+		dir       MigrationDirection
+		err       string
+	}{
+		{"safe up", create, Up, ""},
+		{"destructive up", drop, Up, "Refusing to apply 2_drop_users.sql"},
+		{"safe down", drop, Down, ""},
+		{"destructive down", create, Down, "Refusing to roll back 1_create_users.sql"},
+		{"acknowledged down", acknowledged, Down, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queries := test.migration.Up
+			if test.dir == Down {
+				queries = test.migration.Down
+			}
+			err := checkDestructive(&PlannedMigration{Migration: test.migration, Queries: queries, Direction: test.dir})
+			if test.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var destructiveErr *DestructiveError
+			if !errors.As(err, &destructiveErr) {
+				t.Fatalf("got %v, want *DestructiveError", err)
+			}
+			if !strings.HasPrefix(err.Error(), test.err) || !strings.HasSuffix(err.Error(), "\n  [DROP TABLE] DROP TABLE users") {
+				t.Errorf("got %q, want %s", err, test.err)
+			}
+		})
+	}
+}
+
+func TestExecDestructive(t *testing.T) {
+	useMockUi(t)
+	db := openTestDB(t)
+	source := MemoryMigrationSource{Migrations: []*Migration{
+		{Id: "1_create_users.sql", Up: []string{"CREATE TABLE users (id INTEGER PRIMARY KEY)"}, Down: []string{"DROP TABLE users"}},
+	}}
+
+	if _, err := (MigrationSet{}).Exec(db, "sqlite3", source, Up); err != nil {
+		t.Fatal(err)
+	}
+	// The refused rollback leaves the database unchanged.
+	applied, err := MigrationSet{}.Exec(db, "sqlite3", source, Down)
+	var destructiveErr *DestructiveError
+	if applied != 0 || !errors.As(err, &destructiveErr) || destructiveErr.Direction != Down {
+		t.Fatalf("down = %d, %v", applied, err)
+	}
+	if !tableExists(t, db, "users") {
+		t.Fatal("users dropped by a refused rollback")
+	}
+
+	applied, err = MigrationSet{AllowDestructive: true}.Exec(db, "sqlite3", source, Down)
+	if applied != 1 || err != nil {
+		t.Fatalf("down with AllowDestructive = %d, %v", applied, err)
+	}
+	if tableExists(t, db, "users") {
+		t.Error("users kept by the rollback")
+	}
+}
@@ -0,0 +1,423 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect describes a database to the migration engine: how identifiers and
+// bind variables are written, how the tracking table is created, whether
+// schema changes can be rolled back, how concurrent runs are serialized and
+// what driver errors mean.
+//
+// Support for another database is added by registering a Dialect with
+// RegisterDialect.
+type Dialect interface {
+	// QuoteIdentifier quotes a table, schema or column name.
+	QuoteIdentifier(name string) string
+	// Placeholder returns the bind variable of the n-th argument of a
+	// statement, starting at 1.
+	Placeholder(n int) string
+	// CreateTrackingTable returns the statements creating the tracking table,
+	// with a string id primary key and an applied_at timestamp column, along
+	// with its schema when not empty. They should not fail when the objects
+	// already exist, or fail with an error classified as ErrorAlreadyExists.
+	CreateTrackingTable(schema, table string) []string
+	// TransactionalDDL reports whether schema changes can be rolled back.
+	TransactionalDDL() bool
+	// Lock blocks until conn holds the lock named key and returns the
+	// function releasing it.
+	Lock(ctx context.Context, conn *sql.Conn, key string) (func() error, error)
+	// ClassifyError tells what kind of failure a driver error is.
+	ClassifyError(err error) ErrorClass
+}
+
+// ConnectionValidator is implemented by dialects that need to check the
+// connection settings before using a database.
+type ConnectionValidator interface {
+	ValidateConnection(db *sql.DB) error
+}
+
+// ErrorClass is the kind of failure a driver error stands for.
+type ErrorClass int
+
+const (
+	ErrorUnknown ErrorClass = iota
+	// ErrorAlreadyExists the object being created already exists.
+	ErrorAlreadyExists
+	// ErrorUndefinedObject the table, schema or database does not exist.
+	ErrorUndefinedObject
+	// ErrorConnection the database could not be reached.
+	ErrorConnection
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorAlreadyExists:
+		return "already exists"
+	case ErrorUndefinedObject:
+		return "undefined object"
+	case ErrorConnection:
+		return "connection"
+	default:
+		return "unknown"
+	}
+}
+
+var MigrationDialects = map[string]Dialect{
+	"sqlite3":    SqliteDialect{},
+	"postgresql": PostgresDialect{},
+	"mysql":      MySQLDialect{Engine: "InnoDB", Encoding: "UTF8"},
+	"duckdb":     DuckDBDialect{},
+}
+
+// RegisterDialect makes a dialect available under name, for every function
+// taking a dialect name.
+func RegisterDialect(name string, d Dialect) {
+	MigrationDialects[name] = d
+}
+
+// GetDialect returns the dialect registered under name or one of its
+// aliases.
+func GetDialect(name string) (Dialect, error) {
+	d, ok := MigrationDialects[CanonicalDialect(name)]
+	if !ok {
+		return nil, fmt.Errorf("Unknown dialect: %s", name)
+	}
+	return d, nil
+}
+
+// ClassifyError classifies err with the dialect registered under name.
+func ClassifyError(dialect string, err error) ErrorClass {
+	if err == nil {
+		return ErrorUnknown
+	}
+	d, dErr := GetDialect(dialect)
+	if dErr != nil {
+		return classifyConnectionError(err)
+	}
+	return d.ClassifyError(err)
+}
+
+func quotedTable(d Dialect, schema, table string) string {
+	if schema == "" {
+		return d.QuoteIdentifier(table)
+	}
+	return d.QuoteIdentifier(schema) + "." + d.QuoteIdentifier(table)
+}
+
+// lockKey hashes a lock name into the integer advisory locks expect.
+func lockKey(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum64())
+}
+
+func noLock(ctx context.Context, conn *sql.Conn, key string) (func() error, error) {
+	return func() error { return nil }, nil
+}
+
+// classifyConnectionError recognizes the connection failures common to
+// every driver.
+func classifyConnectionError(err error) ErrorClass {
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr) {
+		return ErrorConnection
+	}
+	message := strings.ToLower(err.Error())
+	for _, s := range []string{"connection refused", "no such host", "connection reset", "broken pipe", "i/o timeout"} {
+		if strings.Contains(message, s) {
+			return ErrorConnection
+		}
+	}
+	return ErrorUnknown
+}
+
+type SqliteDialect struct{}
+
+func (d SqliteDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (d SqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (d SqliteDialect) CreateTrackingTable(schema, table string) []string {
+	return []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s varchar(255) NOT NULL PRIMARY KEY, %s datetime)",
+		quotedTable(d, schema, table), d.QuoteIdentifier("id"), d.QuoteIdentifier("applied_at"))}
+}
+
+func (d SqliteDialect) TransactionalDDL() bool {
+	return true
+}
+
+// Lock is a no-op: sqlite3 serializes writers itself.
+func (d SqliteDialect) Lock(ctx context.Context, conn *sql.Conn, key string) (func() error, error) {
+	return noLock(ctx, conn, key)
+}
+
+func (d SqliteDialect) ClassifyError(err error) ErrorClass {
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "already exists"):
+		return ErrorAlreadyExists
+	case strings.Contains(message, "no such table"):
+		return ErrorUndefinedObject
+	case strings.Contains(message, "unable to open database file"):
+		return ErrorConnection
+	}
+	return classifyConnectionError(err)
+}
+
+type PostgresDialect struct{}
+
+func (d PostgresDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (d PostgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (d PostgresDialect) CreateTrackingTable(schema, table string) []string {
+	var stmts []string
+	if schema != "" {
+		stmts = append(stmts, "CREATE SCHEMA IF NOT EXISTS "+d.QuoteIdentifier(schema))
+	}
+	return append(stmts, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s text NOT NULL PRIMARY KEY, %s timestamp with time zone)",
+		quotedTable(d, schema, table), d.QuoteIdentifier("id"), d.QuoteIdentifier("applied_at")))
+}
+
+func (d PostgresDialect) TransactionalDDL() bool {
+	return true
+}
+
+// Lock takes a session level advisory lock.
+func (d PostgresDialect) Lock(ctx context.Context, conn *sql.Conn, key string) (func() error, error) {
+	id := lockKey(key)
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", id); err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", id)
+		return err
+	}, nil
+}
+
+// sqlStateError is implemented by the errors of github.com/lib/pq and
+// github.com/jackc/pgx.
+type sqlStateError interface {
+	SQLState() string
+}
+
+func (d PostgresDialect) ClassifyError(err error) ErrorClass {
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		state := stateErr.SQLState()
+		switch {
+		case state == "42P07" || state == "42P06" || state == "42710":
+			return ErrorAlreadyExists
+		case state == "42P01" || state == "3F000" || state == "42704" || state == "3D000":
+			return ErrorUndefinedObject
+		case strings.HasPrefix(state, "08") || state == "57P03":
+			return ErrorConnection
+		}
+	}
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "already exists"):
+		return ErrorAlreadyExists
+	case strings.Contains(message, "does not exist"):
+		return ErrorUndefinedObject
+	case strings.Contains(message, "the database system is starting up"):
+		return ErrorConnection
+	}
+	return classifyConnectionError(err)
+}
+
+type MySQLDialect struct {
+	// Engine is the storage engine of the tracking table, e.g. InnoDB.
+	Engine string
+	// Encoding is the character set of the tracking table, e.g. UTF8.
+	Encoding string
+}
+
+func (d MySQLDialect) QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (d MySQLDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (d MySQLDialect) CreateTrackingTable(schema, table string) []string {
+	var stmts []string
+	if schema != "" {
+		stmts = append(stmts, "CREATE SCHEMA IF NOT EXISTS "+d.QuoteIdentifier(schema))
+	}
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s varchar(255) NOT NULL PRIMARY KEY, %s datetime)",
+		quotedTable(d, schema, table), d.QuoteIdentifier("id"), d.QuoteIdentifier("applied_at"))
+	if d.Engine != "" {
+		stmt += " ENGINE=" + d.Engine
+	}
+	if d.Encoding != "" {
+		stmt += " CHARSET=" + d.Encoding
+	}
+	return append(stmts, stmt)
+}
+
+// TransactionalDDL is false: mysql commits implicitly around schema changes.
+func (d MySQLDialect) TransactionalDDL() bool {
+	return false
+}
+
+// Lock takes a named lock with GET_LOCK.
+func (d MySQLDialect) Lock(ctx context.Context, conn *sql.Conn, key string) (func() error, error) {
+	// Lock names are limited to 64 characters.
+	name := fmt.Sprintf("migration_%x", lockKey(key))
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", name).Scan(&acquired); err != nil {
+		return nil, err
+	}
+	if acquired.Int64 != 1 {
+		return nil, fmt.Errorf("Unable to acquire lock %s", name)
+	}
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+		return err
+	}, nil
+}
+
+var mysqlErrorRegex = regexp.MustCompile(`^Error (\d+)`)
+
+func (d MySQLDialect) ClassifyError(err error) ErrorClass {
+	if match := mysqlErrorRegex.FindStringSubmatch(err.Error()); match != nil {
+		switch match[1] {
+		case "1050", "1007", "1061":
+			return ErrorAlreadyExists
+		case "1146", "1049", "1051":
+			return ErrorUndefinedObject
+		case "1040", "1042", "1043", "1047", "1053", "1129", "1130", "2002", "2003", "2005", "2006", "2013":
+			return ErrorConnection
+		}
+	}
+	if strings.Contains(err.Error(), "invalid connection") {
+		return ErrorConnection
+	}
+	return classifyConnectionError(err)
+}
+
+// ValidateConnection makes sure that the parseTime option is configured,
+// otherwise the driver won't map time columns to time.Time. See
+// https://github.com/rubenv/verify-rest/issues/2
+func (d MySQLDialect) ValidateConnection(db *sql.DB) error {
+	var out *time.Time
+	err := db.QueryRow("SELECT NOW()").Scan(&out)
+	if err != nil {
+		if strings.Contains(err.Error(), "[]uint8") && strings.Contains(err.Error(), "*time.Time") {
+			return errors.New(`Cannot parse dates.
+
+Make sure that the parseTime option is supplied to your database connection.
+Check https://github.com/go-sql-driver/mysql#parsetime for more info.`)
+		}
+		return err
+	}
+	return nil
+}
+
+// DuckDBDialect needs a driver registered as "duckdb", such as
+// github.com/duckdb/duckdb-go/v2, which is linked in by the duckdb build tag.
+//
+// Only migrations and their status are supported: schema introspection,
+// schema diffs, model generation and column scaffolding fail with a "not
+// supported by dialect: duckdb" error, and new writes the empty template.
+type DuckDBDialect struct{}
+
+func (d DuckDBDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (d DuckDBDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (d DuckDBDialect) CreateTrackingTable(schema, table string) []string {
+	var stmts []string
+	if schema != "" {
+		stmts = append(stmts, "CREATE SCHEMA IF NOT EXISTS "+d.QuoteIdentifier(schema))
+	}
+	return append(stmts, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s VARCHAR NOT NULL PRIMARY KEY, %s TIMESTAMP)",
+		quotedTable(d, schema, table), d.QuoteIdentifier("id"), d.QuoteIdentifier("applied_at")))
+}
+
+func (d DuckDBDialect) TransactionalDDL() bool {
+	return true
+}
+
+// Lock is a no-op: a DuckDB file is opened by a single writing process.
+func (d DuckDBDialect) Lock(ctx context.Context, conn *sql.Conn, key string) (func() error, error) {
+	return noLock(ctx, conn, key)
+}
+
+func (d DuckDBDialect) ClassifyError(err error) ErrorClass {
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "already exists"):
+		return ErrorAlreadyExists
+	case strings.Contains(message, "does not exist"):
+		return ErrorUndefinedObject
+	case strings.Contains(message, "Could not set lock on file"), strings.Contains(message, "Cannot open file"):
+		return ErrorConnection
+	}
+	return classifyConnectionError(err)
+}
+
+// OracleDialect is not registered by default, use
+// RegisterDialect("godror", OracleDialect{}) with the driver of your choice.
+type OracleDialect struct{}
+
+func (d OracleDialect) QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (d OracleDialect) Placeholder(n int) string {
+	return ":" + strconv.Itoa(n)
+}
+
+// CreateTrackingTable has no IF NOT EXISTS, ClassifyError recognizes
+// ORA-00955 instead.
+func (d OracleDialect) CreateTrackingTable(schema, table string) []string {
+	return []string{fmt.Sprintf("CREATE TABLE %s (%s VARCHAR2(4000) NOT NULL PRIMARY KEY, %s TIMESTAMP WITH TIME ZONE)",
+		quotedTable(d, schema, table), d.QuoteIdentifier("id"), d.QuoteIdentifier("applied_at"))}
+}
+
+func (d OracleDialect) TransactionalDDL() bool {
+	return false
+}
+
+func (d OracleDialect) Lock(ctx context.Context, conn *sql.Conn, key string) (func() error, error) {
+	return noLock(ctx, conn, key)
+}
+
+func (d OracleDialect) ClassifyError(err error) ErrorClass {
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "ORA-00955:"):
+		return ErrorAlreadyExists
+	case strings.Contains(message, "ORA-00942:"):
+		return ErrorUndefinedObject
+	case strings.Contains(message, "ORA-12541:"), strings.Contains(message, "ORA-12514:"), strings.Contains(message, "ORA-03113:"):
+		return ErrorConnection
+	}
+	return classifyConnectionError(err)
+}
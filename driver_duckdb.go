@@ -0,0 +1,7 @@
+//go:build duckdb
+
+package migration
+
+// github.com/duckdb/duckdb-go/v2 registers the "duckdb" driver. It needs cgo
+// and links DuckDB in, so it is only built with the duckdb tag.
+import _ "github.com/duckdb/duckdb-go/v2"
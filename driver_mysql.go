@@ -0,0 +1,5 @@
+//go:build !nomysql
+
+package migration
+
+import _ "github.com/go-sql-driver/mysql"
@@ -0,0 +1,5 @@
+//go:build !nopostgresql
+
+package migration
+
+import _ "github.com/lib/pq"
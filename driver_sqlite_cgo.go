@@ -0,0 +1,7 @@
+//go:build cgo && !purego && !nosqlite3
+
+package migration
+
+// github.com/mattn/go-sqlite3 registers the "sqlite3" driver. It needs cgo,
+// build with CGO_ENABLED=0 or the purego tag to use modernc.org/sqlite.
+import _ "github.com/mattn/go-sqlite3"
@@ -0,0 +1,6 @@
+//go:build (!cgo || purego) && !nosqlite3
+
+package migration
+
+// modernc.org/sqlite registers the "sqlite" driver and needs no cgo.
+import _ "modernc.org/sqlite"
@@ -0,0 +1,119 @@
+//go:build duckdb
+
+package migration
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var duckdbMigrations = MemoryMigrationSource{Migrations: []*Migration{
+	{
+		Id:   "0001_create_users_table.sql",
+		Up:   []string{"CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR NOT NULL)"},
+		Down: []string{"DROP TABLE users"},
+	},
+	{
+		Id:   "0002_add_email_in_users_table.sql",
+		Up:   []string{"ALTER TABLE users ADD COLUMN email VARCHAR", "INSERT INTO users VALUES (1, 'app', 'app@example.com')"},
+		Down: []string{"ALTER TABLE users DROP COLUMN email"},
+	},
+}}
+
+func openDuckDB(t *testing.T, file string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("duckdb", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func duckdbStatus(t *testing.T, db *sql.DB) []*StatusRow {
+	t.Helper()
+	report, err := GetStatus(db, "duckdb", duckdbMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report.Migrations
+}
+
+func TestDuckDBMigrate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.duckdb")
+	db := openDuckDB(t, file)
+	m := New(Config{DB: db, Dialect: "duckdb", MigrationSource: duckdbMigrations})
+
+	// Status of a fresh database creates nothing.
+	for _, row := range duckdbStatus(t, db) {
+		if row.Applied {
+			t.Errorf("%s applied before up", row.Id)
+		}
+	}
+	if err := m.Up(0, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The migrations are recorded in the file.
+	db = openDuckDB(t, file)
+	defer func() { _ = db.Close() }()
+	m.DB = db
+	for _, row := range duckdbStatus(t, db) {
+		if !row.Applied || row.Checksum != ChecksumOK || row.DurationMs == nil {
+			t.Errorf("%s: applied %v, checksum %q, duration %v after up", row.Id, row.Applied, row.Checksum, row.DurationMs)
+		}
+	}
+	var email string
+	if err := db.QueryRow("SELECT email FROM users WHERE id = 1").Scan(&email); err != nil {
+		t.Fatal(err)
+	}
+	if email != "app@example.com" {
+		t.Errorf("email = %q", email)
+	}
+
+	if err := m.Down(0, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range duckdbStatus(t, db) {
+		if row.Applied {
+			t.Errorf("%s applied after down", row.Id)
+		}
+	}
+	if _, err := db.Exec("SELECT * FROM users"); err == nil {
+		t.Error("users exists after down")
+	}
+}
+
+func TestDuckDBSchemaNotSupported(t *testing.T) {
+	db := openDuckDB(t, filepath.Join(t.TempDir(), "app.duckdb"))
+	defer func() { _ = db.Close() }()
+
+	_, snapshotErr := SnapshotSchema(db, "duckdb")
+	_, diffErr := SchemaChanges(&Schema{}, &Schema{}, "duckdb")
+	_, modelErr := ModelSchema(".", "duckdb")
+	_, _, scaffoldErr := ScaffoldMigration("create_users_table", "name:string", nil, "duckdb")
+	for name, err := range map[string]error{
+		"SnapshotSchema":    snapshotErr,
+		"SchemaChanges":     diffErr,
+		"ModelSchema":       modelErr,
+		"ScaffoldMigration": scaffoldErr,
+	} {
+		if err == nil || !strings.HasSuffix(err.Error(), "not supported by dialect: duckdb") {
+			t.Errorf("%s: got %v, want not supported by dialect: duckdb", name, err)
+		}
+	}
+
+	// Without columns the name is not scaffolded, new writes the empty
+	// template instead.
+	up, down, err := ScaffoldMigration("create_users_table", "", nil, "duckdb")
+	if err != nil || up != nil || down != nil {
+		t.Errorf("ScaffoldMigration without columns = %q, %q, %v", up, down, err)
+	}
+}
@@ -0,0 +1,144 @@
+package migration
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Target is a named database migrated by a FanoutRunner.
+type Target struct {
+	Name    string
+	DB      *sql.DB
+	Dialect string
+}
+
+// FanoutRunner applies the same migrations to several databases, possibly of
+// different dialects, and reports the outcome per target.
+type FanoutRunner struct {
+	Targets []Target
+	Source  MigrationSource
+	// TableName name of the tracking table used in every target.
+	TableName string
+	// Concurrency max number of targets migrated at the same time.
+	// Defaults to 1.
+	Concurrency int
+	// AllowDestructive see MigrationSet.AllowDestructive.
+	AllowDestructive bool
+	// NonTransactional and Locking see MigrationSet.
+	NonTransactional NonTransactionalPolicy
+	Locking          bool
+	// SchemaDumpDir, when set, receives the schema of every target in a
+	// directory named after the target.
+	SchemaDumpDir string
+}
+
+// TargetReport is the outcome of migrating a single target.
+type TargetReport struct {
+	Target  string `json:"target"`
+	Dialect string `json:"dialect"`
+	// Applied ids of the migrations applied, in order.
+	Applied []string `json:"applied"`
+	// Skipped ids of the planned migrations that were not attempted because
+	// an earlier one failed.
+	Skipped []string `json:"skipped"`
+	// Failed id of the migration that failed, if any.
+	Failed   string        `json:"failed,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"-"`
+	// DurationMs is Duration in milliseconds.
+	DurationMs int64 `json:"duration_ms"`
+	// Err is the underlying error, usually a *TxError or *PlanError.
+	Err error `json:"-"`
+}
+
+// FanoutReport collects the reports of every target, in Targets order.
+type FanoutReport struct {
+	Targets []*TargetReport `json:"targets"`
+}
+
+// Failed returns the reports of the targets that did not complete.
+func (r *FanoutReport) Failed() []*TargetReport {
+	var failed []*TargetReport
+	for _, t := range r.Targets {
+		if t.Err != nil {
+			failed = append(failed, t)
+		}
+	}
+	return failed
+}
+
+// ExecMax applies at most max migrations to every target. Pass 0 for no limit.
+func (r FanoutRunner) ExecMax(dir MigrationDirection, max int) *FanoutReport {
+	report := &FanoutReport{Targets: make([]*TargetReport, len(r.Targets))}
+
+	concurrency := r.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, target := range r.Targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, target Target) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			report.Targets[i] = r.exec(target, dir, max)
+		}(i, target)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (r FanoutRunner) exec(target Target, dir MigrationDirection, max int) *TargetReport {
+	start := time.Now()
+	report := &TargetReport{
+		Target:  target.Name,
+		Dialect: target.Dialect,
+		Applied: []string{},
+		Skipped: []string{},
+	}
+	defer func() {
+		report.Duration = time.Since(start)
+		report.DurationMs = report.Duration.Milliseconds()
+		if report.Err != nil {
+			report.Error = report.Err.Error()
+		}
+	}()
+
+	// The migrations are planned once, by the run applying them.
+	ms := MigrationSet{
+		TableName:        r.TableName,
+		AllowDestructive: r.AllowDestructive,
+		NonTransactional: r.NonTransactional,
+		Locking:          r.Locking,
+	}
+	if r.SchemaDumpDir != "" {
+		ms.SchemaDumpDir = filepath.Join(r.SchemaDumpDir, target.Name)
+	}
+	planned, applied, err := ms.exec(target.DB, target.Dialect, r.Source, dir, max)
+	report.Err = err
+
+	// Only a *TxError tells which migration failed, other errors happen
+	// before or after the migrations are applied.
+	var txErr *TxError
+	if errors.As(err, &txErr) {
+		report.Failed = txErr.Migration.Id
+	}
+	for i, pm := range planned {
+		switch {
+		case i < applied:
+			report.Applied = append(report.Applied, pm.Id)
+		case pm.Id != report.Failed:
+			report.Skipped = append(report.Skipped, pm.Id)
+		}
+	}
+	return report
+}
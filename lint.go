@@ -0,0 +1,402 @@
+package migration
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+)
+
+// LintRule describes a check performed by the Linter.
+type LintRule struct {
+	ID          string
+	Severity    LintSeverity
+	Description string
+}
+
+var (
+	LintRuleParse               = LintRule{"ML000", LintError, "file can not be parsed"}
+	LintRuleMissingDown         = LintRule{"ML001", LintWarning, "missing or empty Down section"}
+	LintRuleIndexNotConcurrent  = LintRule{"ML002", LintWarning, "CREATE INDEX without CONCURRENTLY locks writes on postgresql"}
+	LintRuleConcurrentInTx      = LintRule{"ML003", LintError, "CONCURRENTLY can not run inside a transaction"}
+	LintRuleCreateNotIdempotent = LintRule{"ML004", LintWarning, "CREATE without IF NOT EXISTS"}
+	LintRuleNotNullNoDefault    = LintRule{"ML005", LintError, "NOT NULL column added without a DEFAULT"}
+	LintRuleFileName            = LintRule{"ML006", LintError, "file name does not match the naming convention"}
+)
+
+// LintRules lists every rule, by ID.
+var LintRules = []LintRule{
+	LintRuleParse,
+	LintRuleMissingDown,
+	LintRuleIndexNotConcurrent,
+	LintRuleConcurrentInTx,
+	LintRuleCreateNotIdempotent,
+	LintRuleNotNullNoDefault,
+	LintRuleFileName,
+}
+
+// DefaultFileNamePattern matches the names produced by the new command.
+var DefaultFileNamePattern = regexp.MustCompile(`^\d+[-_][a-z0-9_]+\.sql$`)
+
+// LintIssue is a single finding of the Linter.
+type LintIssue struct {
+	File     string       `json:"file"`
+	Line     int          `json:"line"`
+	Rule     string       `json:"rule"`
+	Severity LintSeverity `json:"severity"`
+	Message  string       `json:"message"`
+}
+
+func (i *LintIssue) String() string {
+	return fmt.Sprintf("%s:%d: [%s] %s: %s", i.File, i.Line, i.Rule, i.Severity, i.Message)
+}
+
+// Linter checks migration files without touching a database.
+//
+// Findings can be suppressed with a '-- lint:ignore ML002[,ML004]' comment on
+// the offending statement or on the lines right above it, or for the whole
+// file with '-- lint:ignore-file ML001'.
+type Linter struct {
+	Dialect string
+	// FileNamePattern file names must match. Defaults to DefaultFileNamePattern.
+	FileNamePattern *regexp.Regexp
+}
+
+var (
+	lintIgnoreRegex     = regexp.MustCompile(`--\s*lint:ignore\s+([\w,\s]+)`)
+	lintIgnoreFileRegex = regexp.MustCompile(`--\s*lint:ignore-file\s+([\w,\s]+)`)
+	createIndexRegex    = regexp.MustCompile(`(?is)^\s*CREATE\s+(UNIQUE\s+)?INDEX\b`)
+	concurrentlyRegex   = regexp.MustCompile(`(?is)\bCONCURRENTLY\b`)
+	createObjectRegex   = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:UNIQUE\s+)?(TABLE|INDEX|SCHEMA|SEQUENCE|EXTENSION)\b`)
+	ifNotExistsRegex    = regexp.MustCompile(`(?is)\bIF\s+NOT\s+EXISTS\b`)
+	addColumnRegex      = regexp.MustCompile(`(?is)^\s*ADD\s+(\w+)`)
+	notNullRegex        = regexp.MustCompile(`(?is)\bNOT\s+NULL\b`)
+	defaultRegex        = regexp.MustCompile(`(?is)\bDEFAULT\b`)
+	alterTablePrefix    = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?\S+\s+`)
+)
+
+// addNotColumns are the words following ADD in an ALTER TABLE that add
+// something other than a column.
+var addNotColumns = map[string]bool{
+	"CONSTRAINT": true,
+	"PRIMARY":    true,
+	"FOREIGN":    true,
+	"UNIQUE":     true,
+	"INDEX":      true,
+	"KEY":        true,
+	"CHECK":      true,
+	"FULLTEXT":   true,
+	"SPATIAL":    true,
+	"PARTITION":  true,
+	"VALUE":      true,
+}
+
+// Lint checks every .sql file below root, recursively.
+func (l Linter) Lint(dir http.FileSystem, root string) ([]*LintIssue, error) {
+	var issues []*LintIssue
+
+	var walk func(string) error
+	walk = func(current string) error {
+		file, err := dir.Open(current)
+		if err != nil {
+			return err
+		}
+		files, err := file.Readdir(0)
+		_ = file.Close()
+		if err != nil {
+			return err
+		}
+		for _, info := range files {
+			name := path.Join(current, info.Name())
+			if info.IsDir() {
+				if err := walk(name); err != nil {
+					return err
+				}
+			} else if strings.HasSuffix(info.Name(), ".sql") {
+				f, err := dir.Open(name)
+				if err != nil {
+					return err
+				}
+				content, err := io.ReadAll(f)
+				_ = f.Close()
+				if err != nil {
+					return err
+				}
+				issues = append(issues, l.LintFile(strings.TrimPrefix(name, "/"), content)...)
+			}
+		}
+		return nil
+	}
+
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].File != issues[j].File {
+			return issues[i].File < issues[j].File
+		}
+		return issues[i].Line < issues[j].Line
+	})
+	return issues, nil
+}
+
+// LintSource checks the files of the migrations of source. Migrations that
+// are not read from files, such as those of a MemoryMigrationSource, are
+// checked in the form of a file holding their statements.
+func (l Linter) LintSource(source MigrationSource) ([]*LintIssue, error) {
+	switch source := source.(type) {
+	case FileMigrationSource:
+		return l.Lint(http.Dir(source.Dir), "/")
+	case *FileMigrationSource:
+		return l.Lint(http.Dir(source.Dir), "/")
+	case HttpFileSystemMigrationSource:
+		return l.Lint(source.FileSystem, "/")
+	case *HttpFileSystemMigrationSource:
+		return l.Lint(source.FileSystem, "/")
+	case EmbedFileSystemMigrationSource:
+		return l.Lint(http.FS(source.FileSystem), source.Root)
+	case *EmbedFileSystemMigrationSource:
+		return l.Lint(http.FS(source.FileSystem), source.Root)
+	case AssetMigrationSource:
+		return l.lintAssets(source)
+	case *AssetMigrationSource:
+		return l.lintAssets(*source)
+	}
+
+	migrations, err := source.FindMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var issues []*LintIssue
+	for _, migration := range migrations {
+		name := path.Join(migration.Namespace, migration.Id)
+		issues = append(issues, l.LintFile(name, migrationFile(migration))...)
+	}
+	return issues, nil
+}
+
+func (l Linter) lintAssets(source AssetMigrationSource) ([]*LintIssue, error) {
+	files, err := source.AssetDir(source.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var issues []*LintIssue
+	for _, name := range files {
+		if !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		content, err := source.Asset(path.Join(source.Dir, name))
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, l.LintFile(name, content)...)
+	}
+	return issues, nil
+}
+
+// migrationFile returns the content of a file parsing as migration.
+func migrationFile(migration *Migration) []byte {
+	var b bytes.Buffer
+	section := func(direction string, stmts []string, noTransaction bool) {
+		b.WriteString(sqlCmdPrefix + direction)
+		if noTransaction {
+			b.WriteString(" " + optionNoTransaction)
+		}
+		b.WriteString("\n")
+		for _, stmt := range stmts {
+			stmt = strings.TrimSuffix(strings.TrimSpace(stmt), ";")
+			if strings.Contains(stmt, ";") {
+				b.WriteString(sqlCmdPrefix + "StatementBegin\n" + stmt + ";\n" + sqlCmdPrefix + "StatementEnd\n")
+			} else {
+				b.WriteString(stmt + ";\n")
+			}
+		}
+	}
+	section("Up", migration.Up, migration.DisableTransactionUp)
+	section("Down", migration.Down, migration.DisableTransactionDown)
+	return b.Bytes()
+}
+
+// LintFile checks the content of a single migration file.
+func (l Linter) LintFile(name string, content []byte) []*LintIssue {
+	lines := strings.Split(string(content), "\n")
+	fileIgnored := make(map[string]bool)
+	for _, line := range lines {
+		if match := lintIgnoreFileRegex.FindStringSubmatch(line); match != nil {
+			for _, id := range splitRuleIDs(match[1]) {
+				fileIgnored[id] = true
+			}
+		}
+	}
+
+	var issues []*LintIssue
+	report := func(rule LintRule, line int, ignored map[string]bool, format string, args ...interface{}) {
+		if fileIgnored[rule.ID] || ignored[rule.ID] {
+			return
+		}
+		issues = append(issues, &LintIssue{
+			File:     name,
+			Line:     line,
+			Rule:     rule.ID,
+			Severity: rule.Severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	pattern := l.FileNamePattern
+	if pattern == nil {
+		pattern = DefaultFileNamePattern
+	}
+	if base := path.Base(name); !pattern.MatchString(base) {
+		report(LintRuleFileName, 1, nil, "%s does not match %s", base, pattern.String())
+	}
+
+	parsed, err := Parse(bytes.NewReader(content))
+	if err != nil {
+		report(LintRuleParse, 1, nil, "%s", err)
+		return issues
+	}
+
+	if len(parsed.DownStatements) == 0 {
+		if strings.Contains(string(content), sqlCmdPrefix+"Down") {
+			report(LintRuleMissingDown, 1, nil, "Down section has no statements")
+		} else {
+			report(LintRuleMissingDown, 1, nil, "no '-- +migrate Down' section")
+		}
+	}
+
+	cursor := 0
+	check := func(stmts []string, inTransaction bool) {
+		for _, stmt := range stmts {
+			line, ignored := locateStatement(lines, stmt, &cursor)
+			l.lintStatement(stmt, inTransaction, func(rule LintRule, format string, args ...interface{}) {
+				report(rule, line, ignored, format, args...)
+			})
+		}
+	}
+	check(parsed.UpStatements, !parsed.DisableTransactionUp)
+	check(parsed.DownStatements, !parsed.DisableTransactionDown)
+
+	return issues
+}
+
+func (l Linter) lintStatement(stmt string, inTransaction bool, report func(LintRule, string, ...interface{})) {
+	sql := stripSQLComments(stmt)
+	dialect := CanonicalDialect(l.Dialect)
+
+	concurrent := concurrentlyRegex.MatchString(sql)
+	if concurrent && inTransaction {
+		report(LintRuleConcurrentInTx, "CONCURRENTLY requires '-- +migrate Up notransaction' (or Down)")
+	}
+	if dialect == "postgresql" && createIndexRegex.MatchString(sql) && !concurrent {
+		report(LintRuleIndexNotConcurrent, "use CREATE INDEX CONCURRENTLY in a notransaction migration")
+	}
+
+	if match := createObjectRegex.FindStringSubmatch(sql); match != nil && !ifNotExistsRegex.MatchString(sql) {
+		object := strings.ToUpper(match[1])
+		// mysql has no CREATE INDEX IF NOT EXISTS
+		if !(object == "INDEX" && dialect == "mysql") {
+			report(LintRuleCreateNotIdempotent, "CREATE %s without IF NOT EXISTS", object)
+		}
+	}
+
+	if loc := alterTablePrefix.FindStringIndex(sql); loc != nil {
+		for _, clause := range splitTopLevel(sql[loc[1]:], ',') {
+			match := addColumnRegex.FindStringSubmatch(clause)
+			if match == nil || addNotColumns[strings.ToUpper(match[1])] {
+				continue
+			}
+			if notNullRegex.MatchString(clause) && !defaultRegex.MatchString(clause) {
+				report(LintRuleNotNullNoDefault, "%s", strings.TrimSpace(clause))
+			}
+		}
+	}
+}
+
+// locateStatement finds the line number of stmt in lines, starting from
+// cursor, and collects the rules suppressed on it or on the comment lines
+// right above it.
+func locateStatement(lines []string, stmt string, cursor *int) (int, map[string]bool) {
+	stmtLines := strings.Split(strings.TrimRight(stmt, "\n"), "\n")
+	first := ""
+	for _, line := range stmtLines {
+		if strings.TrimSpace(line) != "" {
+			first = strings.TrimSpace(line)
+			break
+		}
+	}
+
+	start := *cursor
+	for i := *cursor; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == first {
+			start = i
+			break
+		}
+	}
+	*cursor = start + 1
+
+	ignored := make(map[string]bool)
+	collect := func(line string) {
+		if match := lintIgnoreRegex.FindStringSubmatch(line); match != nil {
+			for _, id := range splitRuleIDs(match[1]) {
+				ignored[id] = true
+			}
+		}
+	}
+	for _, line := range stmtLines {
+		collect(line)
+	}
+	for i := start - 1; i >= 0 && strings.HasPrefix(strings.TrimSpace(lines[i]), "--"); i-- {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), sqlCmdPrefix) {
+			break
+		}
+		collect(lines[i])
+	}
+
+	return start + 1, ignored
+}
+
+func splitRuleIDs(s string) []string {
+	return strings.FieldsFunc(strings.ToUpper(s), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// splitTopLevel splits s on sep, ignoring separators inside parentheses or
+// quotes.
+func splitTopLevel(s string, sep rune) []string {
+	var parts []string
+	depth := 0
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,239 @@
+package migration
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// lintSummary returns the issues as "file:line:rule" strings.
+func lintSummary(issues []*LintIssue) string {
+	summary := make([]string, len(issues))
+	for i, issue := range issues {
+		summary[i] = fmt.Sprintf("%s:%d:%s", issue.File, issue.Line, issue.Rule)
+	}
+	return strings.Join(summary, " ")
+}
+
+func TestLintFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		file    string
+		content string
+		want    string
+	}{
+		{
+			name: "clean",
+			file: "1_create_users.sql",
+			content: `-- +migrate Up
+CREATE TABLE IF NOT EXISTS users (id int);
+-- +migrate Down
+DROP TABLE users;
+`,
+		},
+		{
+			name:    "parse error",
+			file:    "1_create_users.sql",
+			content: "CREATE TABLE IF NOT EXISTS users (id int);\n",
+			want:    "1:ML000",
+		},
+		{
+			name: "no down section",
+			file: "1_create_users.sql",
+			content: `-- +migrate Up
+CREATE TABLE IF NOT EXISTS users (id int);
+`,
+			want: "1:ML001",
+		},
+		{
+			name: "empty down section",
+			file: "1_create_users.sql",
+			content: `-- +migrate Up
+CREATE TABLE IF NOT EXISTS users (id int);
+-- +migrate Down
+`,
+			want: "1:ML001",
+		},
+		{
+			name:    "index without concurrently",
+			dialect: "postgresql",
+			file:    "1_index_users.sql",
+			content: `-- +migrate Up
+CREATE INDEX IF NOT EXISTS users_name ON users (name);
+-- +migrate Down
+DROP INDEX users_name;
+`,
+			want: "2:ML002",
+		},
+		{
+			name:    "index without concurrently on sqlite3",
+			dialect: "sqlite3",
+			file:    "1_index_users.sql",
+			content: `-- +migrate Up
+CREATE INDEX IF NOT EXISTS users_name ON users (name);
+-- +migrate Down
+DROP INDEX users_name;
+`,
+		},
+		{
+			name:    "concurrently in a transaction",
+			dialect: "postgresql",
+			file:    "1_index_users.sql",
+			content: `-- +migrate Up
+CREATE INDEX CONCURRENTLY IF NOT EXISTS users_name ON users (name);
+-- +migrate Down
+DROP INDEX CONCURRENTLY users_name;
+`,
+			want: "2:ML003 4:ML003",
+		},
+		{
+			name:    "concurrently without transaction",
+			dialect: "postgresql",
+			file:    "1_index_users.sql",
+			content: `-- +migrate Up notransaction
+CREATE INDEX CONCURRENTLY IF NOT EXISTS users_name ON users (name);
+-- +migrate Down notransaction
+DROP INDEX CONCURRENTLY users_name;
+`,
+		},
+		{
+			name: "create without if not exists",
+			file: "1_create_users.sql",
+			content: `-- +migrate Up
+CREATE TABLE users (id int);
+CREATE UNIQUE INDEX users_id ON users (id);
+-- +migrate Down
+DROP TABLE users;
+`,
+			want: "2:ML004 3:ML004",
+		},
+		{
+			name:    "mysql index without if not exists",
+			dialect: "mysql",
+			file:    "1_index_users.sql",
+			content: `-- +migrate Up
+CREATE INDEX users_name ON users (name);
+-- +migrate Down
+DROP INDEX users_name ON users;
+`,
+		},
+		{
+			name: "not null without default",
+			file: "2_add_email.sql",
+			content: `-- +migrate Up
+ALTER TABLE users ADD COLUMN email text NOT NULL, ADD COLUMN age int NOT NULL DEFAULT 0, ADD CONSTRAINT users_age CHECK (age IS NOT NULL);
+-- +migrate Down
+ALTER TABLE users DROP COLUMN email, DROP COLUMN age;
+`,
+			want: "2:ML005",
+		},
+		{
+			name: "file name",
+			file: "CreateUsers.sql",
+			content: `-- +migrate Up
+CREATE TABLE IF NOT EXISTS users (id int);
+-- +migrate Down
+DROP TABLE users;
+`,
+			want: "1:ML006",
+		},
+		{
+			name: "ignored on the statement",
+			file: "1_create_users.sql",
+			content: `-- +migrate Up
+CREATE TABLE users (id int); -- lint:ignore ML004
+-- +migrate Down
+DROP TABLE users;
+`,
+		},
+		{
+			name: "ignored above the statement",
+			file: "1_create_users.sql",
+			content: `-- +migrate Up
+-- The table is created once.
+-- lint:ignore ML004, ML005
+CREATE TABLE users (id int);
+CREATE TABLE posts (id int);
+-- +migrate Down
+DROP TABLE posts;
+DROP TABLE users;
+`,
+			want: "5:ML004",
+		},
+		{
+			name: "ignored in the file",
+			file: "CreateUsers.sql",
+			content: `-- lint:ignore-file ML001,ml006
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS users (id int);
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issues := Linter{Dialect: test.dialect}.LintFile(test.file, []byte(test.content))
+			want := ""
+			if test.want != "" {
+				want = test.file + ":" + strings.ReplaceAll(test.want, " ", " "+test.file+":")
+			}
+			if got := lintSummary(issues); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLintFileNamePattern(t *testing.T) {
+	content := []byte("-- +migrate Up\nCREATE TABLE IF NOT EXISTS users (id int);\n-- +migrate Down\nDROP TABLE users;\n")
+	linter := Linter{FileNamePattern: regexp.MustCompile(`^\d{14}_\w+\.sql$`)}
+	if issues := linter.LintFile("20260101120000_create_users.sql", content); len(issues) != 0 {
+		t.Errorf("got %v", issues)
+	}
+	issues := linter.LintFile("1_create_users.sql", content)
+	if len(issues) != 1 || issues[0].Rule != LintRuleFileName.ID || issues[0].Severity != LintError {
+		t.Errorf("got %v, want ML006", issues)
+	}
+}
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"1_create_users.sql":         "-- +migrate Up\nCREATE TABLE users (id int);\n-- +migrate Down\nDROP TABLE users;\n",
+		"billing/2_create_plans.sql": "-- +migrate Up\nCREATE TABLE IF NOT EXISTS plans (id int);\n",
+		"README.md":                  "CREATE TABLE ignored (id int);\n",
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	issues, err := Linter{}.Lint(http.Dir(dir), "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1_create_users.sql:2:ML004 billing/2_create_plans.sql:1:ML001"
+	if got := lintSummary(issues); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Memory migrations are linted as the file they would be.
+	issues, err = Linter{}.LintSource(MemoryMigrationSource{Migrations: []*Migration{
+		{Id: "1_create_users.sql", Up: []string{"CREATE TABLE users (id int)"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lintSummary(issues); got != "1_create_users.sql:1:ML001 1_create_users.sql:2:ML004" {
+		t.Errorf("LintSource = %q", got)
+	}
+}
@@ -3,12 +3,12 @@ package migration
 import (
 	"database/sql"
 	"embed"
+	"errors"
 	"fmt"
 	"os"
 	"path"
-	"slices"
+	"regexp"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/mitchellh/cli"
@@ -23,23 +23,50 @@ var (
 )
 
 type Commands struct {
-	Up     *UpCommand
-	Down   *DownCommand
-	Redo   *RedoCommand
-	Status *StatusCommand
-	New    *NewCommand
-	Skip   *SkipCommand
+	Up       *UpCommand
+	Down     *DownCommand
+	Redo     *RedoCommand
+	Status   *StatusCommand
+	New      *NewCommand
+	Skip     *SkipCommand
+	Graph    *GraphCommand
+	Plan     *PlanCommand
+	Lint     *LintCommand
+	Verify   *VerifyReversibleCommand
+	Diff     *DiffCommand
+	Generate *GenerateCommand
+	Check    *CheckCommand
 }
 
 type Migrate struct {
-	CmdIndex   int
-	Name       string
-	EmbeddedFS embed.FS
-	DB         *sql.DB
-	IsEmbedded bool
-	Dir        string `yaml:"directory"`
-	TableName  string `yaml:"table"`
-	Dialect    string `yaml:"dialect"`
+	CmdIndex    int
+	Name        string
+	EmbeddedFS  embed.FS
+	DB          *sql.DB
+	IsEmbedded  bool
+	Dir         string   `yaml:"directory"`
+	TableName   string   `yaml:"table"`
+	Dialect     string   `yaml:"dialect"`
+	SchemaName  string   `yaml:"schema"`
+	Tenants     []string `yaml:"tenants"`
+	TenantQuery string   `yaml:"tenant_query"`
+	Targets     []Target `yaml:"-"`
+	// Source see Config.MigrationSource.
+	Source MigrationSource `yaml:"-"`
+	// TemplatesDir see Config.TemplatesDir.
+	TemplatesDir string `yaml:"templates"`
+	// Naming, SequencePadding and NamingFunc see Config.
+	Naming          string     `yaml:"naming"`
+	SequencePadding int        `yaml:"sequence_padding"`
+	NamingFunc      NamingFunc `yaml:"-"`
+	// FileNamePattern regular expression migration file names are linted
+	// against. Defaults to DefaultFileNamePattern.
+	FileNamePattern string `yaml:"file_name_pattern"`
+	// ConnectTimeout see Config.ConnectTimeout.
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	// dataSource is the connection string of the environment, until the
+	// connection is opened by the command needing it.
+	dataSource string
 	Cmd        *cli.CLI
 	Commands   Commands
 }
@@ -53,19 +80,31 @@ func New(cfg Config) *Migrate {
 	if cfg.Dir == "" {
 		cfg.Dir = defaultDir
 	}
-	os.MkdirAll(cfg.Dir, os.ModePerm)
 	if cfg.Dialect == "" {
 		cfg.Dialect = defaultDialect
 	}
+	cfg.Dialect = CanonicalDialect(cfg.Dialect)
 	if cfg.TableName == "" {
 		cfg.TableName = defaultTableName
 	}
+	if cfg.TemplatesDir == "" {
+		cfg.TemplatesDir = defaultTemplatesDir
+	}
 
 	if cfg.TableName != "" {
 		SetTable(cfg.TableName)
 	} else {
 		SetTable("")
 	}
+	if cfg.SchemaName != "" {
+		SetSchema(cfg.SchemaName)
+	}
+	if cfg.AllowDestructive {
+		SetAllowDestructive(true)
+	}
+	if cfg.SchemaDumpDir != "" {
+		SetSchemaDumpDir(cfg.SchemaDumpDir)
+	}
 	i := &cli.BasicUi{Writer: os.Stdout}
 	ui = &cli.ColoredUi{
 		Ui:          i,
@@ -76,20 +115,41 @@ func New(cfg Config) *Migrate {
 	}
 
 	m := &Migrate{
-		CmdIndex:   cfg.CmdIndex,
-		EmbeddedFS: cfg.EmbeddedFS,
-		DB:         cfg.DB,
-		Dir:        cfg.Dir,
-		TableName:  cfg.TableName,
-		Dialect:    cfg.Dialect,
+		CmdIndex:    cfg.CmdIndex,
+		Name:        cfg.Name,
+		EmbeddedFS:  cfg.EmbeddedFS,
+		IsEmbedded:  cfg.IsEmbedded,
+		Source:      cfg.MigrationSource,
+		DB:          cfg.DB,
+		Dir:         cfg.Dir,
+		TableName:   cfg.TableName,
+		Dialect:     cfg.Dialect,
+		SchemaName:  cfg.SchemaName,
+		Tenants:     cfg.Tenants,
+		TenantQuery: cfg.TenantQuery,
+		Targets:     cfg.Targets,
+
+		FileNamePattern: cfg.FileNamePattern,
+		ConnectTimeout:  cfg.ConnectTimeout,
+		TemplatesDir:    cfg.TemplatesDir,
+		Naming:          cfg.Naming,
+		SequencePadding: cfg.SequencePadding,
+		NamingFunc:      cfg.NamingFunc,
 	}
 	m.Commands = Commands{
-		Up:     &UpCommand{migrate: m},
-		Down:   &DownCommand{migrate: m},
-		Redo:   &RedoCommand{migrate: m},
-		Status: &StatusCommand{migrate: m},
-		New:    &NewCommand{migrate: m},
-		Skip:   &SkipCommand{migrate: m},
+		Up:       &UpCommand{migrate: m},
+		Down:     &DownCommand{migrate: m},
+		Redo:     &RedoCommand{migrate: m},
+		Status:   &StatusCommand{migrate: m},
+		New:      &NewCommand{migrate: m},
+		Skip:     &SkipCommand{migrate: m},
+		Graph:    &GraphCommand{migrate: m},
+		Plan:     &PlanCommand{migrate: m},
+		Lint:     &LintCommand{migrate: m},
+		Verify:   &VerifyReversibleCommand{migrate: m},
+		Diff:     &DiffCommand{migrate: m},
+		Generate: &GenerateCommand{migrate: m},
+		Check:    &CheckCommand{migrate: m},
 	}
 	m.Cmd = &cli.CLI{
 		Commands: map[string]cli.CommandFactory{
@@ -111,6 +171,27 @@ func New(cfg Config) *Migrate {
 			"skip": func() (cli.Command, error) {
 				return m.Commands.Skip, nil
 			},
+			"graph": func() (cli.Command, error) {
+				return m.Commands.Graph, nil
+			},
+			"plan": func() (cli.Command, error) {
+				return m.Commands.Plan, nil
+			},
+			"lint": func() (cli.Command, error) {
+				return m.Commands.Lint, nil
+			},
+			"verify-reversible": func() (cli.Command, error) {
+				return m.Commands.Verify, nil
+			},
+			"diff": func() (cli.Command, error) {
+				return m.Commands.Diff, nil
+			},
+			"generate": func() (cli.Command, error) {
+				return m.Commands.Generate, nil
+			},
+			"check": func() (cli.Command, error) {
+				return m.Commands.Check, nil
+			},
 		},
 		HelpFunc: cli.BasicHelpFunc(m.Name),
 		Version:  "1.0.0",
@@ -126,7 +207,27 @@ func (m *Migrate) Skip(limit int, dryRun bool) error {
 	return err
 }
 func (m *Migrate) Status() error {
-	return Status(m.Dir, m.Dialect, m.DB)
+	return PrintStatus(m.DB, m.Dialect, m.source(), StatusFilter{}, "table")
+}
+
+// Check tells whether the database is up to date, without modifying it.
+func (m *Migrate) Check() *CheckResult {
+	return Check(m.DB, m.Dialect, m.source())
+}
+
+// StatusReport returns the filtered status of the migrations.
+func (m *Migrate) StatusReport(filter StatusFilter) (*StatusReport, error) {
+	report, err := GetStatus(m.DB, m.Dialect, m.source())
+	if err != nil {
+		return nil, err
+	}
+	return report.Filter(filter), nil
+}
+
+// PrintStatus writes the filtered status of the migrations in the given
+// format.
+func (m *Migrate) PrintStatus(filter StatusFilter, format string) error {
+	return PrintStatus(m.DB, m.Dialect, m.source(), filter, format)
 }
 func (m *Migrate) New(name string) error {
 	return m.Create(name)
@@ -141,7 +242,7 @@ func (m *Migrate) Down(limit int, dryRun bool) error {
 }
 
 func (m *Migrate) Redo(dryRun bool) error {
-	return Redo(m.Dir, m.Dialect, m.DB, dryRun)
+	return RedoMigration(m.DB, m.Dialect, m.source(), dryRun)
 }
 
 func (m *Migrate) Run() int {
@@ -154,18 +255,131 @@ func (m *Migrate) Run() int {
 	return exitCode
 }
 
-func (m *Migrate) Apply(dir MigrationDirection, dryrun bool, limit int) error {
-	var source MigrationSource
+// source returns the migrations used by every command: Source when set,
+// else EmbeddedFS or Dir.
+func (m *Migrate) source() MigrationSource {
+	if m.Source != nil {
+		return m.Source
+	}
 	if m.IsEmbedded {
-		source = EmbedFileSystemMigrationSource{
+		return EmbedFileSystemMigrationSource{
 			FileSystem: m.EmbeddedFS,
 			Root:       m.Dir,
 		}
-	} else {
-		source = FileMigrationSource{
-			Dir: m.Dir,
+	}
+	return FileMigrationSource{
+		Dir: m.Dir,
+	}
+}
+
+func (m *Migrate) Graph(format string) error {
+	migrations, err := m.source().FindMigrations()
+	if err != nil {
+		return err
+	}
+	return WriteGraph(os.Stdout, migrations, format)
+}
+
+// Plan prints the migrations that would be applied as text or JSON.
+func (m *Migrate) Plan(dir MigrationDirection, limit int, format string) error {
+	plan, err := BuildPlan(m.DB, m.Dialect, m.source(), dir, limit)
+	if err != nil {
+		return fmt.Errorf("Cannot plan migration: %s", err)
+	}
+	switch format {
+	case "json":
+		return plan.WriteJSON(os.Stdout)
+	case "", "text":
+		return plan.WriteText(os.Stdout)
+	default:
+		return fmt.Errorf("Unknown format: %s", format)
+	}
+}
+
+// Lint checks the migration files and prints every finding. It returns an
+// error when at least one finding has error severity.
+func (m *Migrate) Lint() error {
+	linter := Linter{Dialect: m.Dialect}
+	if m.FileNamePattern != "" {
+		pattern, err := regexp.Compile(m.FileNamePattern)
+		if err != nil {
+			return fmt.Errorf("Invalid file name pattern: %s", err)
+		}
+		linter.FileNamePattern = pattern
+	}
+
+	issues, err := linter.LintSource(m.source())
+	if err != nil {
+		return err
+	}
+
+	errs, warnings := 0, 0
+	for _, issue := range issues {
+		if issue.Severity == LintError {
+			errs++
+			ui.Error(issue.String())
+		} else {
+			warnings++
+			ui.Warn(issue.String())
+		}
+	}
+	ui.Output(fmt.Sprintf("%d errors, %d warnings", errs, warnings))
+
+	if errs > 0 {
+		return fmt.Errorf("Lint failed with %d errors", errs)
+	}
+	return nil
+}
+
+// VerifyReversible checks every migration round-trips against a scratch
+// database. Without dsn, a temporary sqlite3 file is used.
+func (m *Migrate) VerifyReversible(dialect, dsn string) error {
+	dialect = CanonicalDialect(dialect)
+	driver, err := dialectDriver(dialect)
+	if err != nil {
+		return err
+	}
+	if dsn == "" {
+		if dialect != "sqlite3" {
+			return fmt.Errorf("A -dsn is needed to verify %s migrations", dialect)
+		}
+		dir, err := os.MkdirTemp("", "migration-verify")
+		if err != nil {
+			return err
 		}
+		defer func() { _ = os.RemoveAll(dir) }()
+		dsn = path.Join(dir, "scratch.db")
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	results, err := VerifyReversible(db, dialect, m.source())
+	if err != nil {
+		return fmt.Errorf("Verification failed: %s", err)
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Ok() {
+			ui.Output(result.String())
+		} else {
+			failed++
+			ui.Error(result.String())
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d migrations are not reversible", failed, len(results))
 	}
+	ui.Output(fmt.Sprintf("All %d migrations are reversible", len(results)))
+	return nil
+}
+
+func (m *Migrate) Apply(dir MigrationDirection, dryrun bool, limit int) error {
+	source := m.source()
 	if dryrun {
 		migrations, _, err := PlanMigration(m.DB, m.Dialect, source, dir, limit)
 		if err != nil {
@@ -173,7 +387,7 @@ func (m *Migrate) Apply(dir MigrationDirection, dryrun bool, limit int) error {
 		}
 
 		for _, pm := range migrations {
-			Print(pm, dir)
+			Print(pm, pm.Direction)
 		}
 	} else {
 		n, err := ExecMax(m.DB, m.Dialect, source, dir, limit)
@@ -191,12 +405,116 @@ func (m *Migrate) Apply(dir MigrationDirection, dryrun bool, limit int) error {
 	return nil
 }
 
+// Validate runs the planned migrations inside a transaction that is always
+// rolled back, so nothing is changed in the database.
+func (m *Migrate) Validate(dir MigrationDirection, limit int) error {
+	n, err := Validate(m.DB, m.Dialect, m.source(), dir, limit)
+	if err != nil {
+		return fmt.Errorf("Validation failed: %s", err)
+	}
+
+	if n == 1 {
+		ui.Output("Validated 1 migration, changes rolled back")
+	} else {
+		ui.Output(fmt.Sprintf("Validated %d migrations, changes rolled back", n))
+	}
+	return nil
+}
+
+func (m *Migrate) tenantRunner(parallel int, continueOnError bool) TenantRunner {
+	r := TenantRunner{
+		DB:          m.DB,
+		Dialect:     m.Dialect,
+		Source:      m.source(),
+		TableName:   m.TableName,
+		Tenants:     m.Tenants,
+		TenantQuery: m.TenantQuery,
+		Parallelism: parallel,
+
+		AllowDestructive: migSet.AllowDestructive,
+		NonTransactional: migSet.NonTransactional,
+		Locking:          migSet.Locking,
+		SchemaDumpDir:    migSet.SchemaDumpDir,
+	}
+	if continueOnError {
+		r.Policy = ContinueOnFailure
+	}
+	return r
+}
+
+// ApplyTenants applies the migrations to every configured tenant schema and
+// prints a per-tenant summary.
+func (m *Migrate) ApplyTenants(dir MigrationDirection, limit, parallel int, continueOnError bool) error {
+	results, err := m.tenantRunner(parallel, continueOnError).ExecMax(dir, limit)
+	if err != nil {
+		return fmt.Errorf("Migration failed: %s", err)
+	}
+	return PrintTenantResults(results)
+}
+
+func (m *Migrate) TenantStatus() error {
+	statuses, err := m.tenantRunner(1, true).Status()
+	if err != nil {
+		ui.Error(err.Error())
+		return err
+	}
+	return PrintTenantStatus(statuses)
+}
+
+// ApplyTargets applies the migrations to every configured target database
+// and prints the report as a table or JSON.
+func (m *Migrate) ApplyTargets(dir MigrationDirection, limit, parallel int, format string) error {
+	if len(m.Targets) == 0 {
+		return errors.New("No targets configured")
+	}
+	r := FanoutRunner{
+		Targets:     m.Targets,
+		Source:      m.source(),
+		TableName:   m.TableName,
+		Concurrency: parallel,
+
+		AllowDestructive: migSet.AllowDestructive,
+		NonTransactional: migSet.NonTransactional,
+		Locking:          migSet.Locking,
+		SchemaDumpDir:    migSet.SchemaDumpDir,
+	}
+	report := r.ExecMax(dir, limit)
+	if err := PrintFanoutReport(report, format); err != nil {
+		return err
+	}
+	if failed := report.Failed(); len(failed) > 0 {
+		return fmt.Errorf("Migration failed for %d of %d targets", len(failed), len(report.Targets))
+	}
+	return nil
+}
+
 func (m *Migrate) SkipMigration(dialect string, curBD *sql.DB, dir MigrationDirection, dryrun bool, limit int) error {
-	source := FileMigrationSource{
-		Dir: m.Dir,
+	if dir != Up {
+		return errors.New("Only up migrations can be skipped")
+	}
+	return m.SkipSelected(dialect, curBD, SkipSelection{Max: limit}, dryrun)
+}
+
+// SkipSelected marks the selected pending migrations as applied without
+// running them. With dryrun, it only lists them.
+func (m *Migrate) SkipSelected(dialect string, curBD *sql.DB, selection SkipSelection, dryrun bool) error {
+	source := m.source()
+
+	if dryrun {
+		migrations, _, err := PlanSkip(curBD, dialect, source, selection)
+		if err != nil {
+			return fmt.Errorf("Cannot plan skip: %s", err)
+		}
+		if len(migrations) == 0 {
+			ui.Output("All migrations have already been applied")
+		}
+		for _, pm := range migrations {
+			ui.Output(fmt.Sprintf("==> Would mark migration %s as applied", pm.Id))
+		}
+		return nil
 	}
 
-	n, err := SkipMax(curBD, dialect, source, dir, limit)
+	n, err := Skip(curBD, dialect, source, selection)
 	if err != nil {
 		return fmt.Errorf("Migration failed: %s", err)
 	}
@@ -215,175 +533,187 @@ func (m *Migrate) SkipMigration(dialect string, curBD *sql.DB, dir MigrationDire
 
 func (m *Migrate) Create(name string) error {
 	name = strings.ToLower(name)
-	if _, err := os.Stat(m.Dir); os.IsNotExist(err) {
+	query, err := m.scaffold(name, "")
+	if err != nil {
 		return err
 	}
-	var query string
-	if strings.Contains(name, "/") {
-		fileName := path.Base(name)
-		query = m.GetQuery(fileName)
-	} else {
-		query = m.GetQuery(name)
+	if query == "" {
+		var b strings.Builder
+		if err := tpl.Execute(&b, nil); err != nil {
+			return err
+		}
+		query = b.String()
+	}
+	return m.writeMigration(name, query)
+}
+
+// writeMigration creates the file of a new migration.
+func (m *Migrate) writeMigration(name, content string) error {
+	// Only commands writing migrations create the directory.
+	if err := os.MkdirAll(m.Dir, os.ModePerm); err != nil {
+		return err
+	}
+	pathName, err := m.migrationPath(name)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(pathName)
+	if err != nil {
+		return err
 	}
-	if query != "" {
-		tpl = template.Must(template.New("new_migration").Parse(query))
+	defer func() { _ = f.Close() }()
+
+	if _, err := f.WriteString(content); err != nil {
+		return err
 	}
 
-	var fileName string
-	var pathName string
+	ui.Output(fmt.Sprintf("Created migration %s", pathName))
+	return nil
+}
 
+// migrationPath returns the path of a new migration file with the given
+// name, creating its sub-directory if needed.
+func (m *Migrate) migrationPath(name string) (string, error) {
+	dir := m.Dir
 	if strings.Contains(name, "/") {
 		// Support sub-directory, e.g., "pipelines/create_pipelines_table.sql"
-		subPath := path.Dir(name)
-		fullDir := path.Join(m.Dir, subPath)
-		if err := os.MkdirAll(fullDir, os.ModePerm); err != nil {
-			return err
+		dir = path.Join(m.Dir, path.Dir(name))
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return "", err
 		}
-		baseName := path.Base(name)
-		// If baseName ends with .sql, use as is, else add timestamp
-		if strings.HasSuffix(baseName, ".sql") {
-			fileName = baseName
-		} else {
-			fileName = fmt.Sprintf("%s-%s.sql", time.Now().Format("20060102150405"), baseName)
+		name = path.Base(name)
+		// If the name ends with .sql, use as is, else apply the naming strategy
+		if strings.HasSuffix(name, ".sql") {
+			return path.Join(dir, name), nil
 		}
-		pathName = path.Join(fullDir, fileName)
-	} else {
-		fileName = fmt.Sprintf("%s-%s.sql", time.Now().Format("20060102150405"), strings.TrimSpace(name))
-		pathName = path.Join(m.Dir, fileName)
 	}
 
-	f, err := os.Create(pathName)
+	naming, err := m.namingFunc()
+	if err != nil {
+		return "", err
+	}
+	var existing []*Migration
+	if m.NamingFunc != nil || m.Naming == NamingSequential {
+		existing, err = FileMigrationSource{Dir: m.Dir}.FindMigrations()
+		if err != nil {
+			return "", err
+		}
+	}
+	fileName, err := naming(strings.TrimSpace(name), existing)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, fileName), nil
+}
+
+// Diff compares the schema described by schemaFile with the current one and
+// writes a migration that brings the latter to the former. The current
+// schema is replayed from the existing migrations, or introspected from the
+// database when from is "database".
+func (m *Migrate) Diff(schemaFile, from, name string) error {
+	f, err := os.Open(schemaFile)
 	if err != nil {
 		return err
 	}
-	defer func() { _ = f.Close() }()
+	desired, err := ParseSchema(f)
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("Cannot parse %s: %s", schemaFile, err)
+	}
 
-	if err := tpl.Execute(f, nil); err != nil {
+	var current *Schema
+	switch from {
+	case "", "migrations":
+		current, err = ReplaySchema(m.source())
+	case "database":
+		current, err = SnapshotSchema(m.DB, m.Dialect)
+	default:
+		return fmt.Errorf("Unknown schema source: %s", from)
+	}
+	if err != nil {
 		return err
 	}
 
-	ui.Output(fmt.Sprintf("Created migration %s", pathName))
-	return nil
+	return m.writeSchemaMigration(current, desired, name)
 }
 
-var allowedObj = []string{"table", "view"}
+// GenerateFromModels compares the tables described by the annotated Go
+// structs in path, see ModelSchema, with the schema implied by the existing
+// migrations and writes a migration for the differences. Tables without a
+// model are left alone.
+func (m *Migrate) GenerateFromModels(path, name string) error {
+	models, err := ModelSchema(path, m.Dialect)
+	if err != nil {
+		return err
+	}
+	if len(models.Tables) == 0 {
+		return fmt.Errorf("No models found in %s", path)
+	}
+	current, err := ReplaySchema(m.source())
+	if err != nil {
+		return err
+	}
 
-func (m *Migrate) GetQuery(migrationName string) string {
-	nameParts := strings.Split(migrationName, `_`)
-	upQuery := ""
-	downQuery := ""
-	defObject := "table"
-	if slices.Contains(allowedObj, nameParts[len(nameParts)-1]) {
-		defObject = nameParts[len(nameParts)-1]
-	}
-	if defObject == "table" {
-		switch nameParts[0] {
-		case "create":
-			if m.Dialect == "postgresql" {
-				tableName := strings.Join(nameParts[1:(len(nameParts)-1)], `_`)
-				createSequence := "CREATE SEQUENCE IF NOT EXISTS " + tableName + "_id_seq;\n"
-				upQuery = createSequence + "CREATE TABLE IF NOT EXISTS " + tableName + `
-(
-	id int8 NOT NULL DEFAULT nextval('` + tableName + `_id_seq'::regclass) PRIMARY KEY, 
-	status varchar(20) DEFAULT 'ACTIVE',
-	is_active bool default false,
-	created_at timestamptz DEFAULT NOW(),
-	updated_at timestamptz DEFAULT NOW(),
-	deleted_at timestamptz
-)` + ";"
-				dropSequenceQuery := "DROP SEQUENCE IF EXISTS " + tableName + "_seq;\n"
-				downQuery = dropSequenceQuery + "DROP TABLE IF EXISTS " + tableName + ";"
-			} else if m.Dialect == "mysql" {
-				tableName := strings.Join(nameParts[1:(len(nameParts)-1)], `_`)
-				upQuery = "CREATE TABLE IF NOT EXISTS " + tableName + `
-(
-	id BIGINT AUTO_INCREMENT PRIMARY KEY, 
-	status varchar(20) DEFAULT 'ACTIVE',
-	is_active bool default false,
-	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-	updated_at DATETIME Null DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-	deleted_at datetime Null
-)` + ";"
-				downQuery = "DROP TABLE IF EXISTS " + tableName + ";"
-			}
-			break
-		case "drop":
-			if m.Dialect == "postgresql" {
-				tableName := strings.Join(nameParts[1:(len(nameParts)-1)], `_`)
-				dropSequenceQuery := "DROP SEQUENCE IF EXISTS " + tableName + "_seq;\n"
-				createSequence := "CREATE SEQUENCE IF NOT EXISTS " + tableName + "_id_seq;\n"
-				upQuery = dropSequenceQuery + "DROP TABLE IF EXISTS " + tableName + ";"
-				downQuery = createSequence + "CREATE TABLE IF NOT EXISTS " + tableName + `
-(
-	id int8 NOT NULL DEFAULT nextval('` + tableName + `_id_seq'::regclass) PRIMARY KEY, 
-	status varchar(20) DEFAULT 'ACTIVE',
-	is_active bool default false,
-	created_at timestamptz DEFAULT NOW(),
-	updated_at timestamptz DEFAULT NOW(),
-	deleted_at timestamptz
-)` + ";"
-			} else if m.Dialect == "mysql" {
-				tableName := strings.Join(nameParts[1:(len(nameParts)-1)], `_`)
-				upQuery = "DROP TABLE IF EXISTS " + tableName + ";"
-				downQuery = "CREATE TABLE IF NOT EXISTS " + tableName + `
-(
-	id BIGINT AUTO_INCREMENT PRIMARY KEY, 
-	status varchar(20) DEFAULT 'ACTIVE',
-	is_active bool default false,
-	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-	updated_at DATETIME Null DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-	deleted_at datetime Null
-)` + ";"
-			}
-			break
-		case "add":
-			for i, part := range nameParts {
-				if part == "in" {
-					field := strings.Join(nameParts[1:i], `_`)
-					tableName := strings.Join(nameParts[(i+1):(len(nameParts)-1)], `_`)
-					upQuery = "ALTER TABLE " + tableName + " ADD COLUMN " + field + " VARCHAR(200)" + ";"
-					downQuery = "ALTER TABLE " + tableName + " DROP COLUMN " + field + ";"
-					break
-				}
-			}
-		case "remove":
-			for i, part := range nameParts {
-				if part == "from" {
-					field := strings.Join(nameParts[1:i], `_`)
-					tableName := strings.Join(nameParts[(i+1):(len(nameParts)-1)], `_`)
-					upQuery = "ALTER TABLE " + tableName + " DROP COLUMN " + field + ";"
-					downQuery = "ALTER TABLE " + tableName + " ADD COLUMN " + field + " VARCHAR(200)" + ";"
-					break
-				}
-			}
-		case "rename":
-			for i, part := range nameParts {
-				if part == "in" {
-					oldTableName := strings.Join(nameParts[1:i], `_`)
-					newTableName := strings.Join(nameParts[(i+1):(len(nameParts)-1)], `_`)
-					upQuery = "ALTER TABLE " + oldTableName + " RENAME TO " + newTableName + ";"
-					downQuery = "ALTER TABLE " + newTableName + " RENAME TO " + oldTableName + ";"
-					break
-				}
-			}
-		case "alter", "change":
-			for i, part := range nameParts {
-				if part == "in" {
-					field := strings.Join(nameParts[1:i], `_`)
-					tableName := strings.Join(nameParts[(i+1):(len(nameParts)-1)], `_`)
-					upQuery = "ALTER TABLE " + tableName + " ALTER COLUMN " + field + " VARCHAR(200)" + ";"
-					downQuery = "ALTER TABLE " + tableName + " ALTER COLUMN " + field + " VARCHAR(200)" + ";"
-					break
-				}
-			}
+	desired := &Schema{Views: current.Views}
+	for _, t := range current.Tables {
+		if models.Table(t.Name) == nil {
+			desired.Tables = append(desired.Tables, t)
 		}
 	}
-	query := fmt.Sprintf(`
--- +migrate Up
-%s
+	desired.Tables = append(desired.Tables, models.Tables...)
+	desired.sort()
+
+	return m.writeSchemaMigration(current, desired, name)
+}
+
+// writeSchemaMigration writes a migration going from current to desired,
+// unless they are the same.
+func (m *Migrate) writeSchemaMigration(current, desired *Schema, name string) error {
+	up, err := SchemaChanges(current, desired, m.Dialect)
+	if err != nil {
+		return err
+	}
+	if len(up) == 0 {
+		ui.Output("Schema is up to date")
+		return nil
+	}
+	down, err := SchemaChanges(desired, current, m.Dialect)
+	if err != nil {
+		return err
+	}
 
--- +migrate Down
-%s
-`, upQuery, downQuery)
+	return m.writeMigration(strings.ToLower(name), migrationContent(up, down))
+}
+
+// migrationContent returns the content of a migration file.
+func migrationContent(up, down []string) string {
+	var b strings.Builder
+	b.WriteString(directionHeader("Up", up))
+	for _, stmt := range up {
+		b.WriteString(stmt + ";\n")
+	}
+	b.WriteString("\n" + directionHeader("Down", down))
+	for _, stmt := range down {
+		b.WriteString(stmt + ";\n")
+	}
+	return b.String()
+}
+
+// directionHeader returns the '-- +migrate Up' or Down line of statements.
+func directionHeader(direction string, stmts []string) string {
+	if NeedsOwnTransaction(stmts) {
+		return "-- +migrate " + direction + " " + optionNoTransaction + "\n"
+	}
+	return "-- +migrate " + direction + "\n"
+}
+
+// GetQuery returns the content of the migration called migrationName as
+// guessed from its name, see ScaffoldMigration, or "" when there is none.
+func (m *Migrate) GetQuery(migrationName string) string {
+	query, err := m.scaffold(migrationName, "")
+	if err != nil {
+		return ""
+	}
 	return query
 }
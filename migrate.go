@@ -2,7 +2,11 @@ package migration
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -11,12 +15,11 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
-
-	"gopkg.in/gorp.v1"
 )
 
 type MigrationDirection int
@@ -26,6 +29,17 @@ const (
 	Down
 )
 
+func (d MigrationDirection) String() string {
+	switch d {
+	case Up:
+		return "up"
+	case Down:
+		return "down"
+	default:
+		return fmt.Sprintf("MigrationDirection(%d)", int(d))
+	}
+}
+
 // MigrationSet provides database parameters for a migration execution
 type MigrationSet struct {
 	// TableName name of the table used to store migration info.
@@ -37,6 +51,24 @@ type MigrationSet struct {
 	//
 	// This should be used sparingly as it is removing a safety check.
 	IgnoreUnknown bool
+	// SearchPath, when set, is applied with SET search_path before the
+	// statements of every migration so unqualified names resolve inside
+	// it, e.g. `"tenant_a", public`. Only supported on postgresql.
+	SearchPath string
+	// AllowDestructive applies migrations containing destructive statements
+	// even when they lack a '-- +migrate Destructive' header.
+	AllowDestructive bool
+	// NonTransactional decides what happens to migrations containing
+	// statements that can not run inside a transaction but are not marked
+	// notransaction. Defaults to NonTransactionalFail.
+	NonTransactional NonTransactionalPolicy
+	// SchemaDumpDir, when set, receives a schema.sql and schema.json
+	// describing the database after every successful ExecMax.
+	SchemaDumpDir string
+	// Locking serializes concurrent runs of ExecMax against the same tracking
+	// table with Dialect.Lock. The lock is held on a connection of its own, so
+	// the pool must allow one more.
+	Locking bool
 }
 
 var migSet = MigrationSet{}
@@ -114,6 +146,29 @@ func SetIgnoreUnknown(v bool) {
 	migSet.IgnoreUnknown = v
 }
 
+// SetAllowDestructive sets the flag that applies migrations containing
+// destructive statements without a '-- +migrate Destructive' header.
+func SetAllowDestructive(v bool) {
+	migSet.AllowDestructive = v
+}
+
+// SetSchemaDumpDir sets the directory schema.sql and schema.json are written
+// to after migrations are applied. Pass "" to disable.
+func SetSchemaDumpDir(dir string) {
+	migSet.SchemaDumpDir = dir
+}
+
+// SetLocking sets whether concurrent runs are serialized with a database lock.
+func SetLocking(locking bool) {
+	migSet.Locking = locking
+}
+
+// SetNonTransactionalPolicy sets what happens to migrations containing
+// statements that can not run inside a transaction.
+func SetNonTransactionalPolicy(p NonTransactionalPolicy) {
+	migSet.NonTransactional = p
+}
+
 type Migration struct {
 	Id   string
 	Up   []string
@@ -121,6 +176,25 @@ type Migration struct {
 
 	DisableTransactionUp   bool
 	DisableTransactionDown bool
+
+	// DependsOn holds the ids of parent migrations. When any migration in a
+	// set declares dependencies, planning follows the dependency graph
+	// instead of the strictly linear Id order.
+	DependsOn []string
+
+	// AllowDestructive acknowledges destructive statements in Up and Down.
+	AllowDestructive bool
+
+	// Namespace is the sub-directory of the migration file, relative to the
+	// root of its source. Empty at the root.
+	Namespace string
+
+	// NonTransactionalUp and NonTransactionalDown see ParsedMigration.
+	NonTransactionalUp   []string
+	NonTransactionalDown []string
+	// parsed is set by ParseMigration, which detects the NonTransactional
+	// statements. They are detected when planning other migrations.
+	parsed bool
 }
 
 func (m Migration) Less(other *Migration) bool {
@@ -136,6 +210,18 @@ func (m Migration) Less(other *Migration) bool {
 	}
 }
 
+// Checksum identifies the Up statements of the migration, which are the
+// ones that changed the database, to tell when an applied migration was
+// edited afterwards.
+func (m Migration) Checksum() string {
+	h := sha256.New()
+	for _, stmt := range m.Up {
+		_, _ = io.WriteString(h, strings.TrimSpace(stmt))
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func (m Migration) isNumeric() bool {
 	return len(m.NumberPrefixMatches()) > 0
 }
@@ -158,6 +244,11 @@ type PlannedMigration struct {
 
 	DisableTransaction bool
 	Queries            []string
+
+	// Direction the Queries are applied in. Catch-up migrations are always
+	// applied Up, whatever direction was requested.
+	Direction MigrationDirection
+	Reason    PlanReason
 }
 
 type byId []*Migration
@@ -169,28 +260,12 @@ func (b byId) Less(i, j int) bool { return b[i].Less(b[j]) }
 type MigrationRecord struct {
 	Id        string    `db:"id"`
 	AppliedAt time.Time `db:"applied_at"`
-}
-
-type OracleDialect struct {
-	gorp.OracleDialect
-}
-
-func (d OracleDialect) IfTableNotExists(command, schema, table string) string {
-	return command
-}
-
-func (d OracleDialect) IfSchemaNotExists(command, schema string) string {
-	return command
-}
-
-func (d OracleDialect) IfTableExists(command, schema, table string) string {
-	return command
-}
-
-var MigrationDialects = map[string]gorp.Dialect{
-	"sqlite3":    gorp.SqliteDialect{},
-	"postgresql": gorp.PostgresDialect{},
-	"mysql":      gorp.MySQLDialect{Engine: "InnoDB", Encoding: "UTF8"},
+	// Checksum of the migration when it was applied, see
+	// Migration.Checksum. Empty when it was not recorded.
+	Checksum string `db:"checksum"`
+	// Duration of the migration, nil when it was not recorded or the
+	// migration was skipped.
+	Duration *time.Duration `db:"duration_ms"`
 }
 
 type MigrationSource interface {
@@ -214,6 +289,9 @@ func (m MemoryMigrationSource) FindMigrations() ([]*Migration, error) {
 	migrations := make([]*Migration, len(m.Migrations))
 	copy(migrations, m.Migrations)
 	sort.Sort(byId(migrations))
+	if err := validateDependencies(migrations); err != nil {
+		return nil, err
+	}
 	return migrations, nil
 }
 
@@ -267,6 +345,7 @@ func findMigrations(dir http.FileSystem, root string) ([]*Migration, error) {
 				if err != nil {
 					return err
 				}
+				migration.Namespace = strings.Trim(strings.TrimPrefix(path.Clean(current), path.Clean(root)), "/")
 				migrations = append(migrations, migration)
 			}
 		}
@@ -280,6 +359,10 @@ func findMigrations(dir http.FileSystem, root string) ([]*Migration, error) {
 	// Make sure migrations are sorted
 	sort.Sort(byId(migrations))
 
+	if err := validateDependencies(migrations); err != nil {
+		return nil, err
+	}
+
 	return migrations, nil
 }
 
@@ -339,6 +422,10 @@ func (a AssetMigrationSource) FindMigrations() ([]*Migration, error) {
 	// Make sure migrations are sorted
 	sort.Sort(byId(migrations))
 
+	if err := validateDependencies(migrations); err != nil {
+		return nil, err
+	}
+
 	return migrations, nil
 }
 
@@ -358,16 +445,15 @@ func ParseMigration(id string, r io.ReadSeeker) (*Migration, error) {
 
 	m.DisableTransactionUp = parsed.DisableTransactionUp
 	m.DisableTransactionDown = parsed.DisableTransactionDown
+	m.DependsOn = parsed.DependsOn
+	m.AllowDestructive = parsed.AllowDestructive
+	m.NonTransactionalUp = parsed.NonTransactionalUp
+	m.NonTransactionalDown = parsed.NonTransactionalDown
+	m.parsed = true
 
 	return m, nil
 }
 
-type SqlExecutor interface {
-	Exec(query string, args ...interface{}) (sql.Result, error)
-	Insert(list ...interface{}) error
-	Delete(list ...interface{}) (int64, error)
-}
-
 // Exec a set of migrations
 //
 // Returns the number of applied migrations.
@@ -391,106 +477,279 @@ func ExecMax(db *sql.DB, dialect string, m MigrationSource, dir MigrationDirecti
 
 // ExecMax Returns the number of applied migrations.
 func (ms MigrationSet) ExecMax(db *sql.DB, dialect string, m MigrationSource, dir MigrationDirection, max int) (int, error) {
-	migrations, dbMap, err := ms.PlanMigration(db, dialect, m, dir, max)
+	_, applied, err := ms.exec(db, dialect, m, dir, max)
+	return applied, err
+}
+
+// exec plans and applies at most max migrations. It returns the planned
+// migrations along with the number of them that were applied.
+func (ms MigrationSet) exec(db *sql.DB, dialect string, m MigrationSource, dir MigrationDirection, max int) ([]*PlannedMigration, int, error) {
+	dialect = CanonicalDialect(dialect)
+
+	if ms.SearchPath != "" && dialect != "postgresql" {
+		return nil, 0, fmt.Errorf("SearchPath is not supported by dialect: %s", dialect)
+	}
+	if ms.Locking {
+		unlock, err := ms.lock(db, dialect)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer func() { _ = unlock() }()
+	}
+
+	migrations, table, err := ms.PlanMigration(db, dialect, m, dir, max)
 	if err != nil {
-		return 0, err
+		return nil, 0, err
+	}
+	// Refuse the whole run before anything is applied
+	if !ms.AllowDestructive {
+		for _, migration := range migrations {
+			if err := checkDestructive(migration); err != nil {
+				return migrations, 0, err
+			}
+		}
+	}
+	if err := table.create(); err != nil {
+		return migrations, 0, err
 	}
 	// Apply migrations
 	applied := 0
 	for _, migration := range migrations {
-		var executor SqlExecutor
+		if err := applyMigration(db, table, migration, ms.SearchPath); err != nil {
+			return migrations, applied, err
+		}
+		applied++
+	}
 
-		if migration.DisableTransaction {
-			executor = dbMap
-		} else {
-			executor, err = dbMap.Begin()
-			if err != nil {
-				return applied, newTxError(migration, err)
-			}
+	if ms.SchemaDumpDir != "" {
+		if err := ms.DumpSchema(db, dialect, ms.SchemaDumpDir); err != nil {
+			return migrations, applied, fmt.Errorf("Migrations applied but the schema could not be dumped: %s", err)
 		}
-		for _, stmt := range migration.Queries {
-			// remove the semicolon from stmt, fix ORA-00922 issue in database oracle
-			stmt = strings.TrimSuffix(stmt, "\n")
-			stmt = strings.TrimSuffix(stmt, " ")
-			stmt = strings.TrimSuffix(stmt, ";")
-			if _, err := executor.Exec(stmt); err != nil {
-				if trans, ok := executor.(*gorp.Transaction); ok {
-					_ = trans.Rollback()
-				}
+	}
 
-				return applied, newTxError(migration, err)
+	return migrations, applied, nil
+}
+
+// applyMigration runs the statements of a planned migration and records it,
+// in a transaction unless the migration disables them.
+func applyMigration(db *sql.DB, table *TrackingTable, migration *PlannedMigration, searchPath string) (err error) {
+	var executor SqlExecutor
+	var trans *sql.Tx
+
+	if migration.DisableTransaction {
+		// Outside a transaction the statements run on one pinned
+		// connection, which is restored before it returns to the pool.
+		conn, release, err := pinConnection(db, table.Dialect, searchPath)
+		if err != nil {
+			return newTxError(migration, err)
+		}
+		defer func() { release(err != nil) }()
+		executor = conn
+	} else {
+		trans, err = table.Begin()
+		if err != nil {
+			return newTxError(migration, err)
+		}
+		executor = trans
+		if searchPath != "" {
+			if _, err := executor.Exec("SET LOCAL search_path TO " + searchPath); err != nil {
+				_ = trans.Rollback()
+				return newTxError(migration, err)
 			}
-			if Callback != nil {
-				Callback(dir, stmt)
+		}
+	}
+	start := time.Now()
+	for _, stmt := range migration.Queries {
+		// remove the semicolon from stmt, fix ORA-00922 issue in database oracle
+		stmt = strings.TrimSuffix(stmt, "\n")
+		stmt = strings.TrimSuffix(stmt, " ")
+		stmt = strings.TrimSuffix(stmt, ";")
+		if isForeignKeyCheck(table.Dialect, stmt) {
+			err = checkForeignKeys(executor, stmt)
+		} else {
+			_, err = executor.Exec(stmt)
+		}
+		if err != nil {
+			if trans != nil {
+				_ = trans.Rollback()
 			}
+
+			return newTxError(migration, err)
 		}
+		if Callback != nil {
+			Callback(migration.Direction, stmt)
+		}
+	}
 
-		switch dir {
-		case Up:
-			start := time.Now()
-			ui.Warn("Migrating " + migration.Id)
-			err = executor.Insert(&MigrationRecord{
-				Id:        migration.Id,
-				AppliedAt: time.Now(),
-			})
-			if err != nil {
-				if trans, ok := executor.(*gorp.Transaction); ok {
-					_ = trans.Rollback()
-				}
-				ui.Error(fmt.Sprintf("Unable to migrate %s, error: %s", migration.Id, err.Error()))
-				return applied, newTxError(migration, err)
+	// A rollback may apply catch-up migrations, each migration is
+	// recorded in its own direction.
+	switch migration.Direction {
+	case Up:
+		ui.Warn("Migrating " + migration.Id)
+		duration := time.Since(start)
+		err = table.Insert(executor, newRecord(migration.Migration, &duration))
+		if err != nil {
+			if trans != nil {
+				_ = trans.Rollback()
 			}
-			ui.Output("Migrated " + migration.Id + "; Time taken: " + time.Since(start).String())
-		case Down:
-			start := time.Now()
-			ui.Warn("Rolling back " + migration.Id)
-			_, err := executor.Delete(&MigrationRecord{
-				Id: migration.Id,
-			})
-			if err != nil {
-				if trans, ok := executor.(*gorp.Transaction); ok {
-					_ = trans.Rollback()
-				}
-				ui.Error(fmt.Sprintf("Unable to rollback %s, error: %s", migration.Id, err.Error()))
-				return applied, newTxError(migration, err)
+			ui.Error(fmt.Sprintf("Unable to migrate %s, error: %s", migration.Id, err.Error()))
+			return newTxError(migration, err)
+		}
+		ui.Output("Migrated " + migration.Id + "; Time taken: " + time.Since(start).String())
+	case Down:
+		ui.Warn("Rolling back " + migration.Id)
+		err := table.Delete(executor, migration.Id)
+		if err != nil {
+			if trans != nil {
+				_ = trans.Rollback()
 			}
-			ui.Output("Rollback Successful " + migration.Id + "; Time taken: " + time.Since(start).String())
-		default:
-			panic("Not possible")
+			ui.Error(fmt.Sprintf("Unable to rollback %s, error: %s", migration.Id, err.Error()))
+			return newTxError(migration, err)
 		}
+		ui.Output("Rollback Successful " + migration.Id + "; Time taken: " + time.Since(start).String())
+	default:
+		panic("Not possible")
+	}
 
-		if trans, ok := executor.(*gorp.Transaction); ok {
-			if err := trans.Commit(); err != nil {
-				return applied, newTxError(migration, err)
+	if trans != nil {
+		if err := trans.Commit(); err != nil {
+			return newTxError(migration, err)
+		}
+	}
+
+	return nil
+}
+
+// pinConnection returns an executor running on a single connection of db,
+// with the given search_path, and the function returning the connection to
+// the pool. The connection is restored to its settings, including the
+// foreign_keys pragma of sqlite3. When the migration failed, the transaction
+// it may have left open, such as the one of a sqlite3 table rebuild, is
+// rolled back first, and the connection, whose state is unknown, is
+// discarded.
+func pinConnection(db *sql.DB, d Dialect, searchPath string) (*connExecutor, func(failed bool), error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var restore []string
+	release := func(failed bool) {
+		if failed {
+			// Fails when no transaction is open.
+			_, _ = conn.ExecContext(ctx, "ROLLBACK")
+		}
+		for _, stmt := range restore {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				failed = true
 			}
 		}
+		if failed {
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		_ = conn.Close()
+	}
 
-		applied++
+	if _, ok := d.(SqliteDialect); ok {
+		var foreignKeys int
+		if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+			release(true)
+			return nil, nil, err
+		}
+		restore = append(restore, fmt.Sprintf("PRAGMA foreign_keys = %d", foreignKeys))
+	}
+	if searchPath != "" {
+		if _, err := conn.ExecContext(ctx, "SET search_path TO "+searchPath); err != nil {
+			release(true)
+			return nil, nil, err
+		}
+		restore = append(restore, "RESET search_path")
 	}
+	return &connExecutor{conn}, release, nil
+}
 
-	return applied, nil
+// connExecutor runs statements on a *sql.Conn.
+type connExecutor struct {
+	conn *sql.Conn
+}
+
+func (c *connExecutor) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.conn.ExecContext(context.Background(), query, args...)
+}
+
+func (c *connExecutor) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.conn.QueryContext(context.Background(), query, args...)
+}
+
+// isForeignKeyCheck tells whether stmt is a sqlite3 PRAGMA foreign_key_check,
+// which reports violations as rows instead of failing.
+func isForeignKeyCheck(d Dialect, stmt string) bool {
+	_, ok := d.(SqliteDialect)
+	return ok && strings.HasPrefix(strings.ToLower(strings.Join(strings.Fields(stmt), " ")), "pragma foreign_key_check")
+}
+
+// checkForeignKeys runs a PRAGMA foreign_key_check and fails when it
+// reports a violation.
+func checkForeignKeys(executor SqlExecutor, stmt string) error {
+	querier, ok := executor.(interface {
+		Query(query string, args ...interface{}) (*sql.Rows, error)
+	})
+	if !ok {
+		_, err := executor.Exec(stmt)
+		return err
+	}
+	rows, err := querier.Query(stmt)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	if !rows.Next() {
+		return rows.Err()
+	}
+	var table, parent string
+	var rowid sql.NullInt64
+	var fkid int
+	if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+		return err
+	}
+	return fmt.Errorf("Foreign key violation: row %d of %s references a missing row of %s", rowid.Int64, table, parent)
 }
 
 // PlanMigration Plan a migration.
-func PlanMigration(db *sql.DB, dialect string, m MigrationSource, dir MigrationDirection, max int) ([]*PlannedMigration, *gorp.DbMap, error) {
+func PlanMigration(db *sql.DB, dialect string, m MigrationSource, dir MigrationDirection, max int) ([]*PlannedMigration, *TrackingTable, error) {
 	return migSet.PlanMigration(db, dialect, m, dir, max)
 }
 
-func (ms MigrationSet) PlanMigration(db *sql.DB, dialect string, m MigrationSource, dir MigrationDirection, max int) ([]*PlannedMigration, *gorp.DbMap, error) {
-	dbMap, err := ms.getMigrationDbMap(db, dialect)
+// PlanMigration changes nothing: the tracking table is created, or given
+// the columns it lacks, by the runs recording migrations.
+func (ms MigrationSet) PlanMigration(db *sql.DB, dialect string, m MigrationSource, dir MigrationDirection, max int) ([]*PlannedMigration, *TrackingTable, error) {
+	dialect = CanonicalDialect(dialect)
+
+	table, err := ms.existingTrackingTable(db, dialect)
 	if err != nil {
 		return nil, nil, err
 	}
-
-	migrations, err := m.FindMigrations()
+	migrationRecords, err := table.Records()
+	if err != nil && table.Dialect.ClassifyError(err) != ErrorUndefinedObject {
+		return nil, nil, err
+	}
+	result, err := ms.plan(dialect, m, migrationRecords, dir, max)
 	if err != nil {
 		return nil, nil, err
 	}
+	return result, table, nil
+}
 
-	var migrationRecords []MigrationRecord
-	_, err = dbMap.Select(&migrationRecords, fmt.Sprintf("SELECT * FROM %s", dbMap.Dialect.QuotedTableForQuery(ms.SchemaName, ms.getTableName())))
+// plan plans the migrations of m given the applied ones.
+func (ms MigrationSet) plan(dialect string, m MigrationSource, migrationRecords []*MigrationRecord, dir MigrationDirection, max int) ([]*PlannedMigration, error) {
+	migrations, err := m.FindMigrations()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	migrations, err = ms.applyTransactionPolicy(dialect, migrations)
+	if err != nil {
+		return nil, err
 	}
 
 	// Sort migrations that have been run by Id.
@@ -511,11 +770,21 @@ func (ms MigrationSet) PlanMigration(db *sql.DB, dialect string, m MigrationSour
 		}
 		for _, existingMigration := range existingMigrations {
 			if _, ok := migrationsSearch[existingMigration.Id]; !ok {
-				return nil, nil, newPlanError(existingMigration, "unknown migration in database")
+				return nil, newPlanError(existingMigration, "unknown migration in database")
 			}
 		}
 	}
 
+	// Migrations declaring dependencies are planned from the graph instead of
+	// the linear order, so unapplied branches are never run out of order.
+	if hasDependencies(migrations) {
+		applied := make(map[string]bool, len(existingMigrations))
+		for _, existingMigration := range existingMigrations {
+			applied[existingMigration.Id] = true
+		}
+		return planDependencies(migrations, applied, dir, max)
+	}
+
 	// Get last migration that was run
 	record := &Migration{}
 	if len(existingMigrations) > 0 {
@@ -543,17 +812,21 @@ func (ms MigrationSet) PlanMigration(db *sql.DB, dialect string, m MigrationSour
 				Migration:          v,
 				Queries:            v.Up,
 				DisableTransaction: v.DisableTransactionUp,
+				Direction:          Up,
+				Reason:             ReasonPending,
 			})
 		} else if dir == Down {
 			result = append(result, &PlannedMigration{
 				Migration:          v,
 				Queries:            v.Down,
 				DisableTransaction: v.DisableTransactionDown,
+				Direction:          Down,
+				Reason:             ReasonRollback,
 			})
 		}
 	}
 
-	return result, dbMap, nil
+	return result, nil
 }
 
 // SkipMax a set of migrations
@@ -562,38 +835,104 @@ func (ms MigrationSet) PlanMigration(db *sql.DB, dialect string, m MigrationSour
 //
 // Returns the number of skipped migrations.
 func SkipMax(db *sql.DB, dialect string, m MigrationSource, dir MigrationDirection, max int) (int, error) {
-	migrations, dbMap, err := PlanMigration(db, dialect, m, dir, max)
+	migrations, table, err := PlanMigration(db, dialect, m, dir, max)
+	if err != nil {
+		return 0, err
+	}
+	return markApplied(db, table, migrations)
+}
+
+// SkipSelection picks the pending migrations marked as applied without
+// being run.
+type SkipSelection struct {
+	// Max number of migrations skipped. 0 for no limit.
+	Max int
+	// To skips the pending migrations up to and including this Id.
+	To string
+	// Only skips the single pending migration with this Id.
+	Only string
+}
+
+// PlanSkip returns the pending migrations the selection would mark as
+// applied, in order.
+func PlanSkip(db *sql.DB, dialect string, m MigrationSource, selection SkipSelection) ([]*PlannedMigration, *TrackingTable, error) {
+	return migSet.PlanSkip(db, dialect, m, selection)
+}
+
+func (ms MigrationSet) PlanSkip(db *sql.DB, dialect string, m MigrationSource, selection SkipSelection) ([]*PlannedMigration, *TrackingTable, error) {
+	if selection.To != "" && selection.Only != "" {
+		return nil, nil, errors.New("Cannot skip both to and only a migration")
+	}
+	migrations, table, err := ms.PlanMigration(db, dialect, m, Up, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if id := selection.To + selection.Only; id != "" {
+		index := slices.IndexFunc(migrations, func(pm *PlannedMigration) bool { return pm.Id == id })
+		if index < 0 {
+			return nil, nil, fmt.Errorf("Migration %s is not pending", id)
+		}
+		if selection.Only != "" {
+			migrations = migrations[index : index+1]
+		} else {
+			migrations = migrations[:index+1]
+		}
+	}
+	if selection.Max > 0 && len(migrations) > selection.Max {
+		migrations = migrations[:selection.Max]
+	}
+	return migrations, table, nil
+}
+
+// Skip marks the selected pending migrations as applied without running
+// them.
+//
+// Returns the number of skipped migrations.
+func Skip(db *sql.DB, dialect string, m MigrationSource, selection SkipSelection) (int, error) {
+	return migSet.Skip(db, dialect, m, selection)
+}
+
+func (ms MigrationSet) Skip(db *sql.DB, dialect string, m MigrationSource, selection SkipSelection) (int, error) {
+	migrations, table, err := ms.PlanSkip(db, dialect, m, selection)
 	if err != nil {
 		return 0, err
 	}
+	return markApplied(db, table, migrations)
+}
 
-	// Skip migrations
+// markApplied records the migrations as applied, each in its own
+// transaction unless it disables them.
+func markApplied(db *sql.DB, table *TrackingTable, migrations []*PlannedMigration) (int, error) {
+	if err := table.create(); err != nil {
+		return 0, err
+	}
 	applied := 0
 	for _, migration := range migrations {
 		var executor SqlExecutor
+		var trans *sql.Tx
+		var err error
 
 		if migration.DisableTransaction {
-			executor = dbMap
+			executor = db
 		} else {
-			executor, err = dbMap.Begin()
+			trans, err = table.Begin()
 			if err != nil {
 				return applied, newTxError(migration, err)
 			}
+			executor = trans
 		}
 
-		err = executor.Insert(&MigrationRecord{
-			Id:        migration.Id,
-			AppliedAt: time.Now(),
-		})
+		err = table.Insert(executor, newRecord(migration.Migration, nil))
 		if err != nil {
-			if trans, ok := executor.(*gorp.Transaction); ok {
+			if trans != nil {
 				_ = trans.Rollback()
 			}
 
 			return applied, newTxError(migration, err)
 		}
 
-		if trans, ok := executor.(*gorp.Transaction); ok {
+		if trans != nil {
 			if err := trans.Commit(); err != nil {
 				return applied, newTxError(migration, err)
 			}
@@ -650,6 +989,8 @@ func ToCatchup(migrations, existingMigrations []*Migration, lastRun *Migration)
 				Migration:          migration,
 				Queries:            migration.Up,
 				DisableTransaction: migration.DisableTransactionUp,
+				Direction:          Up,
+				Reason:             ReasonCatchup,
 			})
 		}
 	}
@@ -661,65 +1002,38 @@ func GetMigrationRecords(db *sql.DB, dialect string) ([]*MigrationRecord, error)
 }
 
 func (ms MigrationSet) GetMigrationRecords(db *sql.DB, dialect string) ([]*MigrationRecord, error) {
-	dbMap, err := ms.getMigrationDbMap(db, dialect)
-	if err != nil {
-		return nil, err
-	}
-
-	var records []*MigrationRecord
-	query := fmt.Sprintf("SELECT * FROM %s ORDER BY %s ASC", dbMap.Dialect.QuotedTableForQuery(ms.SchemaName, ms.getTableName()), dbMap.Dialect.QuoteField("id"))
-	_, err = dbMap.Select(&records, query)
+	table, err := ms.trackingTable(db, dialect)
 	if err != nil {
 		return nil, err
 	}
-
-	return records, nil
+	return table.Records()
 }
 
-func (ms MigrationSet) getMigrationDbMap(db *sql.DB, dialect string) (*gorp.DbMap, error) {
-	d, ok := MigrationDialects[dialect]
-	if !ok {
-		return nil, fmt.Errorf("Unknown dialect: %s", dialect)
+// lock takes the lock of the tracking table with Dialect.Lock and returns
+// the function releasing it.
+func (ms MigrationSet) lock(db *sql.DB, dialect string) (func() error, error) {
+	if db == nil {
+		return nil, ErrNoDatabase
 	}
-
-	// When using the mysql driver, make sure that the parseTime option is
-	// configured, otherwise it won't map time columns to time.Time. See
-	// https://github.com/rubenv/verify-rest/issues/2
-	if dialect == "mysql" {
-		var out *time.Time
-		err := db.QueryRow("SELECT NOW()").Scan(&out)
-		if err != nil {
-			if err.Error() == "sql: Scan error on column index 0: unsupported driver -> Scan pair: []uint8 -> *time.Time" ||
-				err.Error() == "sql: Scan error on column index 0: unsupported Scan, storing driver.Value type []uint8 into type *time.Time" ||
-				err.Error() == "sql: Scan error on column index 0, name \"NOW()\": unsupported Scan, storing driver.Value type []uint8 into type *time.Time" {
-				return nil, errors.New(`Cannot parse dates.
-
-Make sure that the parseTime option is supplied to your database connection.
-Check https://github.com/go-sql-driver/mysql#parsetime for more info.`)
-			} else {
-				return nil, err
-			}
-		}
-	}
-
-	// Create migration database map
-	dbMap := &gorp.DbMap{Db: db, Dialect: d}
-	table := dbMap.AddTableWithNameAndSchema(MigrationRecord{}, ms.SchemaName, ms.getTableName()).SetKeys(false, "Id")
-	// dbMap.TraceOn("", log.New(os.Stdout, "migrate: ", log.Lmicroseconds))
-
-	if dialect == "oci8" || dialect == "godror" {
-		table.ColMap("Id").SetMaxSize(4000)
+	d, err := GetDialect(dialect)
+	if err != nil {
+		return nil, err
 	}
-
-	err := dbMap.CreateTablesIfNotExists()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
 	if err != nil {
-		// Oracle database does not support `if not exists`, so use `ORA-00955:` error code
-		// to check if the table exists.
-		if (dialect == "oci8" || dialect == "godror") && strings.Contains(err.Error(), "ORA-00955:") {
-			return dbMap, nil
-		}
 		return nil, err
 	}
-
-	return dbMap, nil
+	unlock, err := d.Lock(ctx, conn, quotedTable(d, ms.SchemaName, ms.getTableName()))
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("Unable to lock the migrations: %s", err)
+	}
+	return func() error {
+		err := unlock()
+		if closeErr := conn.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
 }
@@ -0,0 +1,404 @@
+package migration
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/oarkflow/migration/pluralize"
+)
+
+// Go types are mapped onto columns per dialect. A struct is a model when it
+// has a TableName method or a field with a `db` or `migrate` tag. Fields are
+// described with a `migrate` tag holding ';' separated options:
+//
+//	type:varchar(100)      column type, instead of the one implied by Go
+//	size:100               varchar length of a string
+//	pk                     primary key; fields named ID are one by default
+//	null / notnull         nullability; pointers and sql.Null* are nullable
+//	default:0              default expression
+//	unique                 unique constraint
+//	index / index:name     index on the column
+//	references:orgs(id)    foreign key
+//	-                      not a column, like `db:"-"`
+//
+// Table names default to the plural snake_case name of the struct. Embedded
+// structs contribute their fields to the structs embedding them.
+
+type modelTypes struct {
+	integer, bigint, smallint, float, double string
+	text, varchar, boolean, timestamp        string
+	blob, json, serial, bigserial            string
+}
+
+var modelDialectTypes = map[string]modelTypes{
+	"sqlite3": {
+		integer: "integer", bigint: "integer", smallint: "integer", float: "real", double: "real",
+		text: "text", varchar: "varchar(%d)", boolean: "boolean", timestamp: "datetime",
+		blob: "blob", json: "text", serial: "integer", bigserial: "integer",
+	},
+	"postgresql": {
+		integer: "integer", bigint: "bigint", smallint: "smallint", float: "real", double: "double precision",
+		text: "text", varchar: "varchar(%d)", boolean: "boolean", timestamp: "timestamp",
+		blob: "bytea", json: "jsonb", serial: "serial", bigserial: "bigserial",
+	},
+	"mysql": {
+		integer: "int", bigint: "bigint", smallint: "smallint", float: "float", double: "double",
+		text: "varchar(255)", varchar: "varchar(%d)", boolean: "tinyint(1)", timestamp: "datetime",
+		blob: "blob", json: "json", serial: "int", bigserial: "bigint",
+	},
+}
+
+// ModelSchema builds the tables described by the annotated Go structs found
+// in path, a directory or a single .go file.
+func ModelSchema(path, dialect string) (*Schema, error) {
+	dialect = CanonicalDialect(dialect)
+
+	types, ok := modelDialectTypes[dialect]
+	if !ok {
+		return nil, fmt.Errorf("Model generation is not supported by dialect: %s", dialect)
+	}
+
+	files, err := parseGoFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	structs := make(map[string]*ast.StructType)
+	tableNames := make(map[string]string)
+	var names []string
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if spec, ok := spec.(*ast.TypeSpec); ok {
+						if st, ok := spec.Type.(*ast.StructType); ok {
+							structs[spec.Name.Name] = st
+							names = append(names, spec.Name.Name)
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				if receiver, name, ok := tableNameMethod(decl); ok {
+					tableNames[receiver] = name
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+
+	// Structs embedded in others are not models of their own.
+	embedded := make(map[string]bool)
+	for _, st := range structs {
+		for _, field := range st.Fields.List {
+			fieldType := field.Type
+			if star, ok := fieldType.(*ast.StarExpr); ok {
+				fieldType = star.X
+			}
+			if ident, ok := fieldType.(*ast.Ident); ok && len(field.Names) == 0 {
+				embedded[ident.Name] = true
+			}
+		}
+	}
+
+	s := &Schema{}
+	for _, name := range names {
+		_, hasTableName := tableNames[name]
+		if !hasTableName && (embedded[name] || !isModel(structs[name])) {
+			continue
+		}
+		tableName, ok := tableNames[name]
+		if !ok {
+			tableName = pluralize.NewClient().Plural(toSnakeCase(name))
+		}
+		t := &SchemaTable{Name: tableName}
+		if err := addModelFields(t, structs[name], structs, types); err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		if len(t.Columns) > 0 {
+			s.Tables = append(s.Tables, t)
+		}
+	}
+	s.sort()
+	return s, nil
+}
+
+func parseGoFiles(path string) ([]*ast.File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{path}
+	if info.IsDir() {
+		if paths, err = filepath.Glob(filepath.Join(path, "*.go")); err != nil {
+			return nil, err
+		}
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, p := range paths {
+		if strings.HasSuffix(p, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, p, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// tableNameMethod recognizes 'func (T) TableName() string { return "name" }'.
+func tableNameMethod(decl *ast.FuncDecl) (string, string, bool) {
+	if decl.Name.Name != "TableName" || decl.Recv == nil || len(decl.Recv.List) != 1 || decl.Body == nil || len(decl.Body.List) != 1 {
+		return "", "", false
+	}
+	receiver := decl.Recv.List[0].Type
+	if star, ok := receiver.(*ast.StarExpr); ok {
+		receiver = star.X
+	}
+	ident, ok := receiver.(*ast.Ident)
+	if !ok {
+		return "", "", false
+	}
+	ret, ok := decl.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return "", "", false
+	}
+	lit, ok := ret.Results[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", "", false
+	}
+	name, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", "", false
+	}
+	return ident.Name, name, true
+}
+
+func isModel(st *ast.StructType) bool {
+	for _, field := range st.Fields.List {
+		if field.Tag == nil {
+			continue
+		}
+		tag := fieldTag(field)
+		if _, ok := tag.Lookup("db"); ok {
+			return true
+		}
+		if _, ok := tag.Lookup("migrate"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldTag(field *ast.Field) reflect.StructTag {
+	if field.Tag == nil {
+		return ""
+	}
+	tag, _ := strconv.Unquote(field.Tag.Value)
+	return reflect.StructTag(tag)
+}
+
+func addModelFields(t *SchemaTable, st *ast.StructType, structs map[string]*ast.StructType, types modelTypes) error {
+	for _, field := range st.Fields.List {
+		tag := fieldTag(field)
+		if tag.Get("db") == "-" || tag.Get("migrate") == "-" {
+			continue
+		}
+
+		if len(field.Names) == 0 {
+			// Embedded struct of the same package, e.g. a common base model.
+			fieldType := field.Type
+			if star, ok := fieldType.(*ast.StarExpr); ok {
+				fieldType = star.X
+			}
+			if ident, ok := fieldType.(*ast.Ident); ok && structs[ident.Name] != nil {
+				if err := addModelFields(t, structs[ident.Name], structs, types); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			if err := addModelField(t, name.Name, field.Type, tag, types); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func addModelField(t *SchemaTable, fieldName string, fieldType ast.Expr, tag reflect.StructTag, types modelTypes) error {
+	columnName := strings.Split(tag.Get("db"), ",")[0]
+	if columnName == "" {
+		columnName = toSnakeCase(fieldName)
+	}
+
+	options := make(map[string]string)
+	for _, option := range strings.Split(tag.Get("migrate"), ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(option), ":")
+		if key != "" {
+			options[strings.ToLower(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	_, pk := options["pk"]
+	pk = pk || columnName == "id"
+
+	columnType, nullable, err := modelColumnType(fieldType, options, types, pk)
+	if err != nil {
+		return fmt.Errorf("%s: %s", fieldName, err)
+	}
+	if _, ok := options["null"]; ok {
+		nullable = true
+	}
+	if _, ok := options["notnull"]; ok || pk {
+		nullable = false
+	}
+
+	t.Columns = append(t.Columns, &SchemaColumn{
+		Name:     columnName,
+		Type:     columnType,
+		Nullable: nullable,
+		Default:  options["default"],
+	})
+	if pk {
+		columns := []string{columnName}
+		for i, c := range t.Constraints {
+			if c.Type == ConstraintPrimaryKey {
+				columns = append(constraintColumns(c), columnName)
+				t.Constraints = append(t.Constraints[:i], t.Constraints[i+1:]...)
+				break
+			}
+		}
+		t.Constraints = append(t.Constraints, &SchemaConstraint{
+			Type:       ConstraintPrimaryKey,
+			Definition: fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(columns, ", ")),
+		})
+	}
+	if _, ok := options["unique"]; ok {
+		t.Constraints = append(t.Constraints, &SchemaConstraint{
+			Type:       ConstraintUnique,
+			Definition: fmt.Sprintf("UNIQUE (%s)", columnName),
+		})
+	}
+	if index, ok := options["index"]; ok {
+		if index == "" {
+			index = fmt.Sprintf("idx_%s_%s", t.Name, columnName)
+		}
+		t.Indexes = append(t.Indexes, &SchemaIndex{Name: index, Columns: []string{columnName}})
+	}
+	if references, ok := options["references"]; ok {
+		definition, ok := referencesDefinition(sqlTokens("REFERENCES " + references))
+		if !ok {
+			return fmt.Errorf("%s: invalid references %q", fieldName, references)
+		}
+		t.Constraints = append(t.Constraints, &SchemaConstraint{
+			Type:       ConstraintForeignKey,
+			Definition: fmt.Sprintf("FOREIGN KEY (%s) %s", columnName, definition),
+		})
+	}
+	return nil
+}
+
+// modelColumnType maps a Go type onto a column type, and reports whether
+// the Go type can hold NULL.
+func modelColumnType(expr ast.Expr, options map[string]string, types modelTypes, pk bool) (string, bool, error) {
+	nullable := false
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+		nullable = true
+	}
+	if columnType, ok := options["type"]; ok && columnType != "" {
+		return columnType, nullable, nil
+	}
+
+	var goType string
+	switch e := expr.(type) {
+	case *ast.Ident:
+		goType = e.Name
+	case *ast.SelectorExpr:
+		if pkg, ok := e.X.(*ast.Ident); ok {
+			goType = pkg.Name + "." + e.Sel.Name
+		}
+	case *ast.ArrayType:
+		if ident, ok := e.Elt.(*ast.Ident); ok && e.Len == nil && (ident.Name == "byte" || ident.Name == "uint8") {
+			return types.blob, nullable, nil
+		}
+		return types.json, nullable, nil
+	case *ast.MapType:
+		return types.json, nullable, nil
+	}
+
+	if strings.HasPrefix(goType, "sql.Null") {
+		nullable = true
+		goType = strings.ToLower(strings.TrimPrefix(goType, "sql.Null"))
+	}
+
+	switch goType {
+	case "int", "int32", "uint", "uint32":
+		if pk {
+			return types.serial, nullable, nil
+		}
+		return types.integer, nullable, nil
+	case "int64", "uint64":
+		if pk {
+			return types.bigserial, nullable, nil
+		}
+		return types.bigint, nullable, nil
+	case "int8", "int16", "uint8", "uint16", "byte":
+		return types.smallint, nullable, nil
+	case "float32":
+		return types.float, nullable, nil
+	case "float64":
+		return types.double, nullable, nil
+	case "string":
+		if size, ok := options["size"]; ok {
+			n, err := strconv.Atoi(size)
+			if err != nil {
+				return "", false, fmt.Errorf("invalid size %q", size)
+			}
+			return fmt.Sprintf(types.varchar, n), nullable, nil
+		}
+		return types.text, nullable, nil
+	case "bool":
+		return types.boolean, nullable, nil
+	case "time.Time", "time":
+		return types.timestamp, nullable, nil
+	case "json.RawMessage":
+		return types.json, nullable, nil
+	}
+	return "", false, fmt.Errorf("no column type for %s, set one with `migrate:\"type:...\"`", goType)
+}
+
+// toSnakeCase turns OrgID into org_id.
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testModels = `package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Timestamps struct {
+	CreatedAt time.Time
+	UpdatedAt *time.Time
+}
+
+type Organization struct {
+	ID   int64
+	Name string ` + "`migrate:\"size:100;unique\"`" + `
+}
+
+type User struct {
+	ID             int
+	OrganizationID int64  ` + "`migrate:\"references:organizations(id);index\"`" + `
+	Email          string ` + "`db:\"email_address\" migrate:\"notnull;index:users_email\"`" + `
+	Nickname       sql.NullString
+	Admin          bool   ` + "`migrate:\"default:false\"`" + `
+	Settings       []byte
+	Internal       string ` + "`migrate:\"-\"`" + `
+	Timestamps
+}
+
+type Person struct {
+	Code string ` + "`migrate:\"pk;type:char(8)\"`" + `
+}
+
+func (Person) TableName() string { return "people" }
+
+// helper is not a model: it has neither tags nor a TableName method.
+type helper struct {
+	value int
+}
+`
+
+func writeTestModels(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "models.go"), []byte(testModels), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Test files are not read.
+	if err := os.WriteFile(filepath.Join(dir, "models_test.go"), []byte("package models\n\ntype Fixture struct {\n\tID int `migrate:\"pk\"`\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestModelSchema(t *testing.T) {
+	dir := writeTestModels(t)
+	s, err := ModelSchema(dir, "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := s.WriteSQL(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `-- Code generated by migration. DO NOT EDIT.
+
+CREATE TABLE organizations (
+	id bigserial NOT NULL,
+	name varchar(100) NOT NULL,
+	PRIMARY KEY (id),
+	UNIQUE (name)
+);
+
+CREATE TABLE people (
+	code char(8) NOT NULL,
+	PRIMARY KEY (code)
+);
+
+CREATE TABLE users (
+	id serial NOT NULL,
+	organization_id bigint NOT NULL,
+	email_address text NOT NULL,
+	nickname text,
+	admin boolean NOT NULL DEFAULT false,
+	settings bytea NOT NULL,
+	created_at timestamp NOT NULL,
+	updated_at timestamp,
+	FOREIGN KEY (organization_id) REFERENCES organizations (id),
+	PRIMARY KEY (id)
+);
+CREATE INDEX idx_users_organization_id ON users (organization_id);
+CREATE INDEX users_email ON users (email_address);
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestModelSchemaDialects(t *testing.T) {
+	dir := writeTestModels(t)
+	tests := []struct {
+		dialect string
+		want    string
+	}{
+		{"postgresql", "serial bigint text text boolean bytea timestamp timestamp"},
+		{"mysql", "int bigint varchar(255) varchar(255) tinyint(1) blob datetime datetime"},
+		{"sqlite3", "integer integer text text boolean blob datetime datetime"},
+	}
+	for _, test := range tests {
+		s, err := ModelSchema(filepath.Join(dir, "models.go"), test.dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		users := s.Table("users")
+		if users == nil {
+			t.Fatalf("%s: no users table", test.dialect)
+		}
+		var types []string
+		for _, c := range users.Columns {
+			types = append(types, c.Type)
+		}
+		if got := strings.Join(types, " "); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.dialect, got, test.want)
+		}
+	}
+
+	if _, err := ModelSchema(dir, "oracle"); err == nil || err.Error() != "Model generation is not supported by dialect: oracle" {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package migration
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Naming strategies of Config.Naming.
+const (
+	// NamingTimestamp prefixes new migrations with a 14 digits timestamp:
+	// 20060102150405-name.sql.
+	NamingTimestamp = "timestamp"
+	// NamingSequential prefixes new migrations with the number following
+	// the highest existing one: 0001_name.sql.
+	NamingSequential = "sequential"
+)
+
+const (
+	defaultSequencePadding = 4
+	timestampDigits        = 14
+)
+
+// NamingFunc returns the file name, without directory, of a new migration
+// called name given the existing migrations.
+type NamingFunc func(name string, existing []*Migration) (string, error)
+
+// TimestampNaming names migrations after the current time.
+func TimestampNaming(name string, existing []*Migration) (string, error) {
+	return fmt.Sprintf("%s-%s.sql", time.Now().Format("20060102150405"), name), nil
+}
+
+// SequentialNaming returns a NamingFunc numbering migrations from 1, with
+// numbers left padded with zeros to padding digits. Migrations prefixed with
+// a timestamp are left out of the sequence. Two migrations sharing a number
+// are an error, missing numbers are reported as warnings.
+func SequentialNaming(padding int) NamingFunc {
+	return func(name string, existing []*Migration) (string, error) {
+		next, err := nextSequenceNumber(existing)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%0*d_%s.sql", padding, next, name), nil
+	}
+}
+
+func nextSequenceNumber(existing []*Migration) (int64, error) {
+	ids := map[int64][]string{}
+	for _, m := range existing {
+		matches := m.NumberPrefixMatches()
+		if len(matches) == 0 || len(matches[1]) >= timestampDigits {
+			continue
+		}
+		n := m.VersionInt()
+		ids[n] = append(ids[n], m.Id)
+	}
+
+	numbers := make([]int64, 0, len(ids))
+	for n, sameNumber := range ids {
+		if len(sameNumber) > 1 {
+			return 0, fmt.Errorf("Duplicate migration number %d: %s", n, strings.Join(sameNumber, ", "))
+		}
+		numbers = append(numbers, n)
+	}
+	if len(numbers) == 0 {
+		return 1, nil
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	var missing []string
+	expected := int64(1)
+	for _, n := range numbers {
+		for ; expected < n; expected++ {
+			missing = append(missing, strconv.FormatInt(expected, 10))
+		}
+		expected = n + 1
+	}
+	if len(missing) > 0 && ui != nil {
+		ui.Warn(fmt.Sprintf("Missing migration numbers: %s", strings.Join(missing, ", ")))
+	}
+	return numbers[len(numbers)-1] + 1, nil
+}
+
+// namingFunc returns the naming strategy of m.
+func (m *Migrate) namingFunc() (NamingFunc, error) {
+	if m.NamingFunc != nil {
+		return m.NamingFunc, nil
+	}
+	switch m.Naming {
+	case "", NamingTimestamp:
+		return TimestampNaming, nil
+	case NamingSequential:
+		padding := m.SequencePadding
+		if padding <= 0 {
+			padding = defaultSequencePadding
+		}
+		return SequentialNaming(padding), nil
+	default:
+		return nil, fmt.Errorf("Unknown naming strategy: %s", m.Naming)
+	}
+}
@@ -0,0 +1,93 @@
+package migration
+
+import (
+	"regexp"
+	"testing"
+)
+
+func namingMigrations(ids ...string) []*Migration {
+	migrations := make([]*Migration, len(ids))
+	for i, id := range ids {
+		migrations[i] = &Migration{Id: id}
+	}
+	return migrations
+}
+
+func TestSequentialNaming(t *testing.T) {
+	tests := []struct {
+		name     string
+		padding  int
+		existing []*Migration
+		want     string
+		warning  string
+		err      string
+	}{
+		{name: "first", padding: 4, want: "0001_create_users.sql"},
+		{name: "next", padding: 4, existing: namingMigrations("0001_a.sql", "0002_b.sql"), want: "0003_create_users.sql"},
+		{name: "wider than padding", padding: 1, existing: namingMigrations("9_a.sql"), want: "10_create_users.sql", warning: "Missing migration numbers: 1, 2, 3, 4, 5, 6, 7, 8\n"},
+		{name: "gaps", padding: 4, existing: namingMigrations("0001_a.sql", "0004_b.sql", "0006_c.sql"), want: "0007_create_users.sql", warning: "Missing migration numbers: 2, 3, 5\n"},
+		{name: "timestamps left out", padding: 4, existing: namingMigrations("20260101120000-a.sql", "0001_b.sql"), want: "0002_create_users.sql"},
+		{name: "only timestamps", padding: 4, existing: namingMigrations("20260101120000-a.sql"), want: "0001_create_users.sql"},
+		{name: "unnumbered left out", padding: 4, existing: namingMigrations("seed.sql", "0001_b.sql"), want: "0002_create_users.sql"},
+		{name: "duplicate", padding: 4, existing: namingMigrations("0001_a.sql", "0002_b.sql", "2_c.sql"), err: "Duplicate migration number 2: 0002_b.sql, 2_c.sql"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := useMockUi(t)
+			got, err := SequentialNaming(test.padding)("create_users", test.existing)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+			if warning := mock.ErrorWriter.String(); warning != test.warning {
+				t.Errorf("warning %q, want %q", warning, test.warning)
+			}
+		})
+	}
+}
+
+func TestNamingFunc(t *testing.T) {
+	custom := func(name string, existing []*Migration) (string, error) { return "custom_" + name + ".sql", nil }
+	tests := []struct {
+		name    string
+		migrate Migrate
+		want    string
+		err     string
+	}{
+		{name: "default", migrate: Migrate{}, want: `^\d{14}-create_users\.sql$`},
+		{name: "timestamp", migrate: Migrate{Naming: NamingTimestamp}, want: `^\d{14}-create_users\.sql$`},
+		{name: "sequential", migrate: Migrate{Naming: NamingSequential}, want: `^0001_create_users\.sql$`},
+		{name: "sequential padding", migrate: Migrate{Naming: NamingSequential, SequencePadding: 6}, want: `^000001_create_users\.sql$`},
+		{name: "custom", migrate: Migrate{Naming: "ignored", NamingFunc: custom}, want: `^custom_create_users\.sql$`},
+		{name: "unknown", migrate: Migrate{Naming: "uuid"}, err: "Unknown naming strategy: uuid"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			naming, err := test.migrate.namingFunc()
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := naming("create_users", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !regexp.MustCompile(test.want).MatchString(got) {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package migration
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PlanReason explains why a migration is part of a plan.
+type PlanReason string
+
+const (
+	// ReasonPending the migration has not been applied yet.
+	ReasonPending PlanReason = "pending"
+	// ReasonCatchup the migration is older than the last applied one but was
+	// never applied, for example after a merge. It is always applied Up.
+	ReasonCatchup PlanReason = "catch-up"
+	// ReasonRollback the migration is applied and will be rolled back.
+	ReasonRollback PlanReason = "rollback"
+)
+
+// StatementCount returns the number of statements that will be executed.
+func (pm *PlannedMigration) StatementCount() int {
+	return len(pm.Queries)
+}
+
+// Destructive returns the destructive operations found in the planned
+// statements, in statement order and without duplicates.
+func (pm *PlannedMigration) Destructive() []string {
+	var kinds []string
+	seen := make(map[string]bool)
+	for _, s := range ClassifyStatements(pm.Queries) {
+		for _, kind := range s.Kinds {
+			if !seen[kind] {
+				seen[kind] = true
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+	return kinds
+}
+
+// Plan is the list of migrations a run would apply, in order.
+type Plan struct {
+	Direction  MigrationDirection
+	Migrations []*PlannedMigration
+}
+
+type planStepJSON struct {
+	Id          string     `json:"id"`
+	Direction   string     `json:"direction"`
+	Reason      PlanReason `json:"reason"`
+	Transaction bool       `json:"transaction"`
+	Statements  int        `json:"statements"`
+	Destructive []string   `json:"destructive"`
+}
+
+type planJSON struct {
+	Direction  string          `json:"direction"`
+	Migrations []*planStepJSON `json:"migrations"`
+}
+
+func (p *Plan) MarshalJSON() ([]byte, error) {
+	out := planJSON{
+		Direction:  p.Direction.String(),
+		Migrations: make([]*planStepJSON, 0, len(p.Migrations)),
+	}
+	for _, pm := range p.Migrations {
+		destructive := pm.Destructive()
+		if destructive == nil {
+			destructive = []string{}
+		}
+		out.Migrations = append(out.Migrations, &planStepJSON{
+			Id:          pm.Id,
+			Direction:   pm.Direction.String(),
+			Reason:      pm.Reason,
+			Transaction: !pm.DisableTransaction,
+			Statements:  pm.StatementCount(),
+			Destructive: destructive,
+		})
+	}
+	return json.Marshal(out)
+}
+
+// WriteText writes a human readable description of the plan.
+func (p *Plan) WriteText(w io.Writer) error {
+	var b strings.Builder
+	if len(p.Migrations) == 0 {
+		b.WriteString("Nothing to do!\n")
+	}
+	for i, pm := range p.Migrations {
+		transaction := "transaction"
+		if pm.DisableTransaction {
+			transaction = "no transaction"
+		}
+		fmt.Fprintf(&b, "%3d. %s %s (%s, %s, %d statements)", i+1, pm.Direction, pm.Id, pm.Reason, transaction, pm.StatementCount())
+		if destructive := pm.Destructive(); len(destructive) > 0 {
+			fmt.Fprintf(&b, " destructive: %s", strings.Join(destructive, ", "))
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the plan as indented JSON.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// BuildPlan plans a migration and returns it as a Plan, without applying
+// anything.
+func BuildPlan(db *sql.DB, dialect string, m MigrationSource, dir MigrationDirection, max int) (*Plan, error) {
+	return migSet.BuildPlan(db, dialect, m, dir, max)
+}
+
+func (ms MigrationSet) BuildPlan(db *sql.DB, dialect string, m MigrationSource, dir MigrationDirection, max int) (*Plan, error) {
+	migrations, _, err := ms.PlanMigration(db, dialect, m, dir, max)
+	if err != nil {
+		return nil, err
+	}
+	return &Plan{
+		Direction:  dir,
+		Migrations: migrations,
+	}, nil
+}
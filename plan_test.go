@@ -0,0 +1,96 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+)
+
+var planMigrations = []*Migration{
+	{Id: "1_create_users.sql", Up: []string{"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)"}, Down: []string{"DROP TABLE users"}},
+	{Id: "2_create_posts.sql", Up: []string{"CREATE TABLE posts (id INTEGER PRIMARY KEY)"}, Down: []string{"DROP TABLE posts"}},
+	{Id: "3_add_email.sql", Up: []string{"ALTER TABLE users ADD COLUMN email TEXT"}, Down: []string{"ALTER TABLE users DROP COLUMN email"}},
+	{Id: "4_vacuum.sql", Up: []string{"VACUUM"}, DisableTransactionUp: true},
+}
+
+func TestBuildPlan(t *testing.T) {
+	useMockUi(t)
+	db := openTestDB(t)
+	ms := MigrationSet{}
+
+	// Planning a fresh database creates nothing.
+	plan, err := ms.BuildPlan(db, "sqlite3", MemoryMigrationSource{Migrations: planMigrations}, Up, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Migrations) != 4 {
+		t.Errorf("planned %d migrations on a fresh database, want 4", len(plan.Migrations))
+	}
+	if tableExists(t, db, "gorp_migrations") {
+		t.Error("plan created the tracking table")
+	}
+
+	// 2 is left behind, as after a merge.
+	applied := MemoryMigrationSource{Migrations: []*Migration{planMigrations[0], planMigrations[2]}}
+	if _, err := ms.Exec(db, "sqlite3", applied, Up); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir  MigrationDirection
+		max  int
+		text string
+		json string
+	}{
+		{
+			dir: Up,
+			text: "  1. up 2_create_posts.sql (catch-up, transaction, 1 statements)\n" +
+				"  2. up 4_vacuum.sql (pending, no transaction, 1 statements)\n",
+			json: `{"direction":"up","migrations":[` +
+				`{"id":"2_create_posts.sql","direction":"up","reason":"catch-up","transaction":true,"statements":1,"destructive":[]},` +
+				`{"id":"4_vacuum.sql","direction":"up","reason":"pending","transaction":false,"statements":1,"destructive":[]}]}`,
+		},
+		{
+			// Catch-up migrations are applied Up even in a Down plan, and
+			// do not count towards max.
+			dir: Down,
+			max: 1,
+			text: "  1. up 2_create_posts.sql (catch-up, transaction, 1 statements)\n" +
+				"  2. down 3_add_email.sql (rollback, transaction, 1 statements) destructive: DROP COLUMN\n",
+			json: `{"direction":"down","migrations":[` +
+				`{"id":"2_create_posts.sql","direction":"up","reason":"catch-up","transaction":true,"statements":1,"destructive":[]},` +
+				`{"id":"3_add_email.sql","direction":"down","reason":"rollback","transaction":true,"statements":1,"destructive":["DROP COLUMN"]}]}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.dir.String(), func(t *testing.T) {
+			plan, err := ms.BuildPlan(db, "sqlite3", MemoryMigrationSource{Migrations: planMigrations}, test.dir, test.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var text strings.Builder
+			if err := plan.WriteText(&text); err != nil {
+				t.Fatal(err)
+			}
+			if text.String() != test.text {
+				t.Errorf("text:\n%s\nwant:\n%s", text.String(), test.text)
+			}
+			json, err := plan.MarshalJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(json) != test.json {
+				t.Errorf("json:\n%s\nwant:\n%s", json, test.json)
+			}
+		})
+	}
+}
+
+func TestPlanWriteTextEmpty(t *testing.T) {
+	var b strings.Builder
+	if err := (&Plan{Direction: Up}).WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "Nothing to do!\n" {
+		t.Errorf("got %q", b.String())
+	}
+}
@@ -0,0 +1,146 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ReversibilityResult is the outcome of checking a single migration.
+type ReversibilityResult struct {
+	Migration string
+	// Residue lists the schema differences left behind by Down, see
+	// DiffSchemas.
+	Residue []string
+	// Drift lists the schema differences between the first and the second
+	// application of Up.
+	Drift []string
+	// Stage is the step that failed: "up", "down", "re-up" or "snapshot".
+	Stage string
+	Err   error
+}
+
+// Ok reports whether the migration is fully reversible.
+func (r *ReversibilityResult) Ok() bool {
+	return r.Err == nil && len(r.Residue) == 0 && len(r.Drift) == 0
+}
+
+func (r *ReversibilityResult) String() string {
+	if r.Ok() {
+		return r.Migration + ": ok"
+	}
+	var problems []string
+	if len(r.Residue) > 0 {
+		problems = append(problems, "down leaves residue:\n  "+strings.Join(r.Residue, "\n  "))
+	}
+	if len(r.Drift) > 0 {
+		problems = append(problems, "re-applying up gives a different schema:\n  "+strings.Join(r.Drift, "\n  "))
+	}
+	if r.Err != nil {
+		problems = append(problems, fmt.Sprintf("%s failed: %s", r.Stage, r.Err))
+	}
+	return r.Migration + ": " + strings.Join(problems, "\n")
+}
+
+// VerifyReversible checks that the Down of every migration reverses its Up.
+// Against a scratch database, each migration in turn is applied up, rolled
+// back and applied up again, comparing schema snapshots along the way.
+//
+// The database must not contain any applied migration. Verification stops at
+// the first migration that fails to apply.
+func VerifyReversible(db *sql.DB, dialect string, m MigrationSource) ([]*ReversibilityResult, error) {
+	return migSet.VerifyReversible(db, dialect, m)
+}
+
+func (ms MigrationSet) VerifyReversible(db *sql.DB, dialect string, m MigrationSource) ([]*ReversibilityResult, error) {
+	// Scratch databases may drop anything, and their schema is not worth
+	// dumping.
+	ms.AllowDestructive = true
+	ms.SchemaDumpDir = ""
+
+	records, err := ms.GetMigrationRecords(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 {
+		return nil, fmt.Errorf("Cannot verify: the database already has %d applied migrations, use a scratch database", len(records))
+	}
+
+	planned, _, err := ms.PlanMigration(db, dialect, m, Up, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*ReversibilityResult
+	for _, pm := range planned {
+		result := &ReversibilityResult{Migration: pm.Id}
+		results = append(results, result)
+
+		fail := func(stage string, err error) {
+			result.Stage = stage
+			result.Err = err
+		}
+
+		before, err := ms.SnapshotSchema(db, dialect)
+		if err != nil {
+			fail("snapshot", err)
+			break
+		}
+		if _, err := ms.ExecMax(db, dialect, m, Up, 1); err != nil {
+			fail("up", err)
+			break
+		}
+		applied, err := ms.SnapshotSchema(db, dialect)
+		if err != nil {
+			fail("snapshot", err)
+			break
+		}
+		if _, err := ms.ExecMax(db, dialect, m, Down, 1); err != nil {
+			fail("down", err)
+			break
+		}
+		after, err := ms.SnapshotSchema(db, dialect)
+		if err != nil {
+			fail("snapshot", err)
+			break
+		}
+		result.Residue = DiffSchemas(before, after)
+
+		if _, err := ms.ExecMax(db, dialect, m, Up, 1); err != nil {
+			fail("re-up", err)
+			break
+		}
+		reapplied, err := ms.SnapshotSchema(db, dialect)
+		if err != nil {
+			fail("snapshot", err)
+			break
+		}
+		result.Drift = DiffSchemas(applied, reapplied)
+	}
+
+	return results, nil
+}
+
+// TestingT is the subset of *testing.T used by AssertReversible.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// AssertReversible runs VerifyReversible and reports every migration whose
+// Down does not reverse its Up as a test error.
+func AssertReversible(t TestingT, db *sql.DB, dialect string, m MigrationSource) {
+	t.Helper()
+
+	results, err := VerifyReversible(db, dialect, m)
+	if err != nil {
+		t.Fatalf("%s", err)
+		return
+	}
+	for _, result := range results {
+		if !result.Ok() {
+			t.Errorf("%s", result)
+		}
+	}
+}
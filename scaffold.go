@@ -0,0 +1,579 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Column specs describe the columns of a scaffolded table as comma separated
+// name:type[:modifier...] items, for instance
+//
+//	email:string:unique,age:int:null,org_id:ref:orgs,price:decimal(10,2):default=0
+//
+// Types are string, string(n), text, int, bigint, smallint, float, double,
+// bool, timestamp, date, json, uuid, blob and ref, followed by the referenced
+// table and optionally its column: ref:orgs or ref:orgs(code). Other types
+// are used as is. Modifiers are null, unique, index, pk and default=expr.
+// Columns are NOT NULL unless marked null. An id primary key is added unless
+// a column is marked pk or named id.
+
+// ColumnSpecTable builds the table described by column specs for the
+// dialect.
+func ColumnSpecTable(table, specs, dialect string) (*SchemaTable, error) {
+	dialect = CanonicalDialect(dialect)
+	types, ok := modelDialectTypes[dialect]
+	if !ok {
+		return nil, fmt.Errorf("Column scaffolding is not supported by dialect: %s", dialect)
+	}
+
+	t := &SchemaTable{Name: table}
+	var pk []string
+	for _, spec := range splitTopLevel(specs, ',') {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		column, isPK, err := addColumnSpec(t, spec, dialect, types)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid column %q: %s", spec, err)
+		}
+		if isPK {
+			pk = append(pk, column)
+		}
+	}
+	if len(t.Columns) == 0 {
+		return nil, fmt.Errorf("No columns in %q", specs)
+	}
+
+	if len(pk) == 0 {
+		if t.Column("id") == nil {
+			t.Columns = append([]*SchemaColumn{idColumn(dialect, types)}, t.Columns...)
+		}
+		pk = []string{"id"}
+	}
+	t.Constraints = append([]*SchemaConstraint{{
+		Type:       ConstraintPrimaryKey,
+		Definition: fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(pk, ", ")),
+	}}, t.Constraints...)
+	return t, nil
+}
+
+// idColumn returns the auto incremented id column of the dialect.
+func idColumn(dialect string, types modelTypes) *SchemaColumn {
+	columnType := types.bigserial
+	if dialect == "mysql" {
+		columnType += " AUTO_INCREMENT"
+	}
+	return &SchemaColumn{Name: "id", Type: columnType}
+}
+
+// addColumnSpec adds the column described by spec to t, along with its
+// constraints and indexes. It returns the name of the column and whether it
+// is part of the primary key.
+func addColumnSpec(t *SchemaTable, spec, dialect string, types modelTypes) (string, bool, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", false, fmt.Errorf("expected name:type")
+	}
+	name, kind, modifiers := parts[0], strings.ToLower(parts[1]), parts[2:]
+
+	column := &SchemaColumn{Name: name}
+	if kind == "ref" {
+		if len(modifiers) == 0 || modifiers[0] == "" {
+			return "", false, fmt.Errorf("ref needs the referenced table, as ref:table")
+		}
+		target := modifiers[0]
+		modifiers = modifiers[1:]
+		if !strings.Contains(target, "(") {
+			target += "(id)"
+		}
+		definition, ok := referencesDefinition(sqlTokens("REFERENCES " + target))
+		if !ok {
+			return "", false, fmt.Errorf("invalid reference %q", target)
+		}
+		column.Type = types.bigint
+		t.Constraints = append(t.Constraints, &SchemaConstraint{
+			Type:       ConstraintForeignKey,
+			Definition: fmt.Sprintf("FOREIGN KEY (%s) %s", name, definition),
+		})
+		if dialect != "mysql" {
+			// mysql indexes foreign keys itself.
+			t.Indexes = append(t.Indexes, &SchemaIndex{Name: fmt.Sprintf("idx_%s_%s", t.Name, name), Columns: []string{name}})
+		}
+	} else {
+		column.Type = columnSpecType(kind, parts[1], dialect, types)
+	}
+
+	pk := false
+	for _, modifier := range modifiers {
+		key, value, hasValue := strings.Cut(modifier, "=")
+		switch strings.ToLower(key) {
+		case "null":
+			column.Nullable = true
+		case "notnull":
+			column.Nullable = false
+		case "pk":
+			pk = true
+		case "unique":
+			t.Constraints = append(t.Constraints, &SchemaConstraint{
+				Type:       ConstraintUnique,
+				Definition: fmt.Sprintf("UNIQUE (%s)", name),
+			})
+		case "index":
+			t.Indexes = append(t.Indexes, &SchemaIndex{Name: fmt.Sprintf("idx_%s_%s", t.Name, name), Columns: []string{name}})
+		case "default":
+			if !hasValue {
+				return "", false, fmt.Errorf("default needs a value, as default=expr")
+			}
+			column.Default = value
+		default:
+			return "", false, fmt.Errorf("unknown modifier %s", modifier)
+		}
+	}
+	if pk {
+		column.Nullable = false
+	}
+	t.Columns = append(t.Columns, column)
+	return name, pk, nil
+}
+
+// columnSpecType maps the type of a column spec onto a column type.
+func columnSpecType(kind, raw, dialect string, types modelTypes) string {
+	if size, ok := strings.CutPrefix(kind, "string("); ok && strings.HasSuffix(size, ")") {
+		return "varchar(" + size
+	}
+	switch kind {
+	case "string":
+		return fmt.Sprintf(types.varchar, 255)
+	case "text":
+		return "text"
+	case "int", "integer":
+		return types.integer
+	case "bigint":
+		return types.bigint
+	case "smallint":
+		return types.smallint
+	case "float":
+		return types.float
+	case "double":
+		return types.double
+	case "bool", "boolean":
+		return types.boolean
+	case "timestamp", "datetime":
+		return types.timestamp
+	case "date":
+		return "date"
+	case "json":
+		return types.json
+	case "blob", "bytes":
+		return types.blob
+	case "uuid":
+		switch dialect {
+		case "postgresql":
+			return "uuid"
+		case "mysql":
+			return "char(36)"
+		}
+		return "text"
+	}
+	return raw
+}
+
+// ScaffoldMigration returns the statements of the migration called name,
+// guessed from its name, and the statements reverting them:
+//
+//	create_<table>_table                    creates the table
+//	drop_<table>_table                      drops the table
+//	add_<column>_in_<table>_table           adds a column
+//	remove_<column>_from_<table>_table      drops a column
+//	alter_<column>_in_<table>_table         changes a column, as does change_
+//	rename_<old>_to_<new>_in_<table>_table  renames a column
+//	rename_<old>_in_<new>_table             renames a table
+//
+// Column specs, when given, describe the columns created, added or changed,
+// which are otherwise the usual columns of a new table and a nullable
+// varchar(200). Tables are looked up in current, the schema of the existing
+// migrations, so that Down restores what Up drops or changes and that sqlite3
+// tables, which support few ALTER TABLE actions, can be rebuilt. Columns of
+// sqlite3 tables that no migration creates can only be added or dropped.
+//
+// No statements are returned for names that match none of the above.
+func ScaffoldMigration(name, columns string, current *Schema, dialect string) ([]string, []string, error) {
+	dialect = CanonicalDialect(dialect)
+	types, ok := modelDialectTypes[dialect]
+	if !ok {
+		if columns != "" {
+			return nil, nil, fmt.Errorf("Column scaffolding is not supported by dialect: %s", dialect)
+		}
+		return nil, nil, nil
+	}
+	if current == nil {
+		current = &Schema{}
+	}
+	s := &scaffolder{dialect: dialect, types: types, current: current}
+
+	base := strings.ToLower(strings.TrimSuffix(path.Base(name), ".sql"))
+	parts := strings.Split(base, "_")
+	if len(parts) >= 3 && parts[len(parts)-1] == "table" {
+		op, words := parts[0], parts[1:len(parts)-1]
+		if columns != "" {
+			switch op {
+			case "create", "add", "alter", "change":
+			default:
+				return nil, nil, fmt.Errorf("Columns can not be given to %s migrations", op)
+			}
+		}
+		switch op {
+		case "create":
+			return s.create(strings.Join(words, "_"), columns)
+		case "drop":
+			return s.drop(strings.Join(words, "_"))
+		case "add":
+			if column, table, ok := splitNameAt(words, "in"); ok {
+				return s.add(column, table, columns)
+			}
+		case "remove":
+			if column, table, ok := splitNameAt(words, "from"); ok {
+				return s.remove(column, table)
+			}
+		case "alter", "change":
+			if column, table, ok := splitNameAt(words, "in"); ok {
+				return s.alter(column, table, columns)
+			}
+		case "rename":
+			if names, table, ok := splitNameAt(words, "in"); ok {
+				if from, to, ok := splitNameAt(strings.Split(names, "_"), "to"); ok {
+					return s.renameColumn(table, from, to)
+				}
+				return s.renameTable(names, table)
+			}
+		}
+	}
+	if columns != "" {
+		return nil, nil, fmt.Errorf("Columns can not be given to %s, expected create_<table>_table, add_<column>_in_<table>_table or alter_<column>_in_<table>_table", base)
+	}
+	return nil, nil, nil
+}
+
+// splitNameAt splits the words of a migration name around the first
+// occurrence of sep.
+func splitNameAt(words []string, sep string) (string, string, bool) {
+	i := slices.Index(words, sep)
+	if i < 1 || i == len(words)-1 {
+		return "", "", false
+	}
+	return strings.Join(words[:i], "_"), strings.Join(words[i+1:], "_"), true
+}
+
+type scaffolder struct {
+	dialect string
+	types   modelTypes
+	current *Schema
+}
+
+// table returns a copy of the table as created by the existing migrations,
+// or an empty table when none creates it.
+func (s *scaffolder) table(name string) (*SchemaTable, bool) {
+	t := s.current.Table(name)
+	if t == nil {
+		return &SchemaTable{Name: name}, false
+	}
+	return copyTable(t), true
+}
+
+// changes returns the statements turning table from into table to and
+// back, either being nil when the table does not exist on that side.
+func (s *scaffolder) changes(from, to *SchemaTable, known bool) ([]string, []string, error) {
+	fromSchema, toSchema := &Schema{}, &Schema{}
+	if from != nil {
+		fromSchema.Tables = []*SchemaTable{from}
+	}
+	if to != nil {
+		toSchema.Tables = []*SchemaTable{to}
+	}
+	up, err := SchemaChanges(fromSchema, toSchema, s.dialect)
+	if err != nil {
+		return nil, nil, err
+	}
+	down, err := SchemaChanges(toSchema, fromSchema, s.dialect)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !known && from != nil && to != nil && (rebuildsTable(up, from.Name) || rebuildsTable(down, from.Name)) {
+		// Rebuilding a table from the few columns named by the migration
+		// would lose the others.
+		return nil, nil, fmt.Errorf("sqlite3 rebuilds table %s to change it, which needs its definition but no migration creates it", from.Name)
+	}
+	return up, down, nil
+}
+
+// rebuildsTable tells whether the statements rebuild the sqlite3 table.
+func rebuildsTable(stmts []string, table string) bool {
+	for _, stmt := range stmts {
+		if strings.HasPrefix(stmt, "CREATE TABLE _"+table+"_new ") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scaffolder) create(table, columns string) ([]string, []string, error) {
+	t := s.defaultTable(table)
+	if columns != "" {
+		var err error
+		if t, err = ColumnSpecTable(table, columns, s.dialect); err != nil {
+			return nil, nil, err
+		}
+	}
+	return s.changes(nil, t, true)
+}
+
+func (s *scaffolder) drop(table string) ([]string, []string, error) {
+	t, known := s.table(table)
+	if !known {
+		// Down can only recreate the usual columns.
+		t = s.defaultTable(table)
+	}
+	return s.changes(t, nil, true)
+}
+
+func (s *scaffolder) add(column, table, columns string) ([]string, []string, error) {
+	from, known := s.table(table)
+	to := copyTable(from)
+	if columns == "" {
+		columns = column + ":" + fmt.Sprintf(s.types.varchar, 200) + ":null"
+	}
+	added, err := s.columnSpecs(table, columns)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range added.Columns {
+		if from.Column(c.Name) != nil {
+			return nil, nil, fmt.Errorf("Table %s already has a column %s", table, c.Name)
+		}
+	}
+	to.Columns = append(to.Columns, added.Columns...)
+	to.Constraints = append(to.Constraints, added.Constraints...)
+	to.Indexes = append(to.Indexes, added.Indexes...)
+	if !known && s.dialect == "sqlite3" {
+		up, _, err := s.changes(from, to, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		if rebuildsTable(up, table) {
+			return nil, nil, fmt.Errorf("sqlite3 rebuilds table %s to add a NOT NULL column without default or a constraint, which needs its definition but no migration creates it", table)
+		}
+		return up, sqliteDropColumns(added), nil
+	}
+	return s.changes(from, to, known)
+}
+
+func (s *scaffolder) remove(column, table string) ([]string, []string, error) {
+	from, known := s.table(table)
+	if !known {
+		// Down can only guess the dropped column.
+		from.Columns = []*SchemaColumn{s.defaultColumn(column)}
+	} else if from.Column(column) == nil {
+		return nil, nil, fmt.Errorf("Table %s has no column %s", table, column)
+	}
+	to := copyTable(from)
+	to.dropColumn(column)
+	to.dropColumnReferences(column)
+	if !known && s.dialect == "sqlite3" {
+		_, down, err := s.changes(from, to, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		return sqliteDropColumns(from), down, nil
+	}
+	return s.changes(from, to, known)
+}
+
+// sqliteDropColumns drops the columns of t and their indexes without
+// rebuilding the table, which needs sqlite3 3.35 or later and fails when
+// the columns are part of a constraint.
+func sqliteDropColumns(t *SchemaTable) []string {
+	var stmts []string
+	for _, index := range t.Indexes {
+		stmts = append(stmts, "DROP INDEX "+index.Name)
+	}
+	for _, c := range t.Columns {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", t.Name, c.Name))
+	}
+	return stmts
+}
+
+func (s *scaffolder) alter(column, table, columns string) ([]string, []string, error) {
+	from, known := s.table(table)
+	if known && from.Column(column) == nil {
+		return nil, nil, fmt.Errorf("Table %s has no column %s", table, column)
+	}
+	if !known && s.dialect == "sqlite3" {
+		return nil, nil, fmt.Errorf("sqlite3 rebuilds table %s to change it, which needs its definition but no migration creates it", table)
+	}
+	if columns == "" {
+		columns = column + ":" + fmt.Sprintf(s.types.varchar, 200) + ":null"
+	}
+	altered, err := s.columnSpecs(table, columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !known {
+		// The previous definition of the columns is unknown, Down is left
+		// to be written by hand.
+		d := &schemaDiffer{dialect: s.dialect}
+		for _, c := range altered.Columns {
+			d.alterColumn(table, &SchemaColumn{Name: c.Name}, c)
+		}
+		for _, c := range altered.Constraints {
+			d.add("ALTER TABLE %s ADD CONSTRAINT %s %s", table, d.constraintName(altered, c), c.Definition)
+		}
+		for _, index := range altered.Indexes {
+			d.add("%s", index.CreateSQL(table))
+		}
+		return d.stmts, nil, nil
+	}
+
+	to := copyTable(from)
+	for _, c := range altered.Columns {
+		i := slices.IndexFunc(to.Columns, func(other *SchemaColumn) bool { return other.Name == c.Name })
+		if i < 0 {
+			return nil, nil, fmt.Errorf("Table %s has no column %s", table, c.Name)
+		}
+		to.Columns[i] = c
+	}
+	to.Constraints = append(to.Constraints, altered.Constraints...)
+	to.Indexes = append(to.Indexes, altered.Indexes...)
+	return s.changes(from, to, true)
+}
+
+func (s *scaffolder) renameColumn(table, from, to string) ([]string, []string, error) {
+	if t, known := s.table(table); known {
+		if t.Column(from) == nil {
+			return nil, nil, fmt.Errorf("Table %s has no column %s", table, from)
+		}
+		if t.Column(to) != nil {
+			return nil, nil, fmt.Errorf("Table %s already has a column %s", table, to)
+		}
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", table, from, to)},
+		[]string{fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", table, to, from)}, nil
+}
+
+func (s *scaffolder) renameTable(from, to string) ([]string, []string, error) {
+	if _, known := s.table(to); known {
+		return nil, nil, fmt.Errorf("Table %s already exists", to)
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %s RENAME TO %s", from, to)},
+		[]string{fmt.Sprintf("ALTER TABLE %s RENAME TO %s", to, from)}, nil
+}
+
+// columnSpecs returns a table holding the columns described by specs, along
+// with their constraints and indexes, without adding a primary key.
+func (s *scaffolder) columnSpecs(table, specs string) (*SchemaTable, error) {
+	t := &SchemaTable{Name: table}
+	for _, spec := range splitTopLevel(specs, ',') {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		_, pk, err := addColumnSpec(t, spec, s.dialect, s.types)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid column %q: %s", spec, err)
+		}
+		if pk {
+			return nil, fmt.Errorf("Invalid column %q: the primary key of an existing table can not be changed", spec)
+		}
+	}
+	if len(t.Columns) == 0 {
+		return nil, fmt.Errorf("No columns in %q", specs)
+	}
+	return t, nil
+}
+
+// defaultColumn is the column added or changed when no column spec is given.
+func (s *scaffolder) defaultColumn(name string) *SchemaColumn {
+	return &SchemaColumn{Name: name, Type: fmt.Sprintf(s.types.varchar, 200), Nullable: true}
+}
+
+// defaultTable returns the columns of a new table when no column spec is
+// given.
+func (s *scaffolder) defaultTable(table string) *SchemaTable {
+	timestamp, now := s.types.timestamp, "CURRENT_TIMESTAMP"
+	if s.dialect == "postgresql" {
+		timestamp, now = "timestamptz", "NOW()"
+	}
+	updated := now
+	if s.dialect == "mysql" {
+		updated += " ON UPDATE CURRENT_TIMESTAMP"
+	}
+	return &SchemaTable{
+		Name: table,
+		Columns: []*SchemaColumn{
+			idColumn(s.dialect, s.types),
+			{Name: "status", Type: fmt.Sprintf(s.types.varchar, 20), Nullable: true, Default: "'ACTIVE'"},
+			{Name: "is_active", Type: s.types.boolean, Nullable: true, Default: "false"},
+			{Name: "created_at", Type: timestamp, Nullable: true, Default: now},
+			{Name: "updated_at", Type: timestamp, Nullable: true, Default: updated},
+			{Name: "deleted_at", Type: timestamp, Nullable: true},
+		},
+		Constraints: []*SchemaConstraint{{Type: ConstraintPrimaryKey, Definition: "PRIMARY KEY (id)"}},
+	}
+}
+
+// copyTable returns a copy of t that can be changed without changing t.
+func copyTable(t *SchemaTable) *SchemaTable {
+	copied := &SchemaTable{Name: t.Name}
+	for _, c := range t.Columns {
+		column := *c
+		copied.Columns = append(copied.Columns, &column)
+	}
+	for _, c := range t.Constraints {
+		constraint := *c
+		copied.Constraints = append(copied.Constraints, &constraint)
+	}
+	for _, i := range t.Indexes {
+		index := *i
+		index.Columns = slices.Clone(i.Columns)
+		copied.Indexes = append(copied.Indexes, &index)
+	}
+	return copied
+}
+
+// scaffold returns the content of the migration called name, see
+// ScaffoldMigration, or "" when its name does not tell what it does.
+func (m *Migrate) scaffold(name, columns string) (string, error) {
+	current, err := ReplaySchema(m.source())
+	if err != nil {
+		// The first migration creates the directory.
+		if !errors.Is(err, fs.ErrNotExist) && ui != nil {
+			ui.Warn(fmt.Sprintf("Cannot replay the existing migrations, their tables are unknown: %s", err))
+		}
+		current = &Schema{}
+	}
+	up, down, err := ScaffoldMigration(name, columns, current, m.Dialect)
+	if err != nil {
+		return "", err
+	}
+	if len(up) == 0 && len(down) == 0 {
+		return "", nil
+	}
+	return migrationContent(up, down), nil
+}
+
+// CreateWithColumns creates a create_<table>_table, add_<column>_in_<table>_table
+// or alter_<column>_in_<table>_table migration whose columns are described by
+// column specs, see ScaffoldMigration.
+func (m *Migrate) CreateWithColumns(name, columns string) error {
+	name = strings.ToLower(name)
+	content, err := m.scaffold(name, columns)
+	if err != nil {
+		return err
+	}
+	return m.writeMigration(name, content)
+}
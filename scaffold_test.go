@@ -0,0 +1,271 @@
+package migration
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const scaffoldColumns = "email:string:unique,age:int:null,org_id:ref:orgs,price:decimal(10,2):default=0"
+
+func TestScaffoldCreateTable(t *testing.T) {
+	tests := []struct {
+		dialect string
+		columns string
+		want    []string
+	}{
+		{"postgresql", scaffoldColumns, []string{
+			"CREATE TABLE users (\n\tid bigserial NOT NULL,\n\temail varchar(255) NOT NULL,\n\tage integer,\n\torg_id bigint NOT NULL,\n\tprice decimal(10,2) NOT NULL DEFAULT 0,\n\tCONSTRAINT users_pkey PRIMARY KEY (id),\n\tCONSTRAINT users_email_key UNIQUE (email),\n\tCONSTRAINT users_org_id_fkey FOREIGN KEY (org_id) REFERENCES orgs (id)\n)",
+			"CREATE INDEX idx_users_org_id ON users (org_id)",
+		}},
+		{"mysql", scaffoldColumns, []string{
+			"CREATE TABLE users (\n\tid bigint AUTO_INCREMENT NOT NULL,\n\temail varchar(255) NOT NULL,\n\tage int,\n\torg_id bigint NOT NULL,\n\tprice decimal(10,2) NOT NULL DEFAULT 0,\n\tPRIMARY KEY (id),\n\tCONSTRAINT email UNIQUE (email),\n\tCONSTRAINT users_org_id_fkey FOREIGN KEY (org_id) REFERENCES orgs (id)\n)",
+		}},
+		{"sqlite3", scaffoldColumns, []string{
+			"CREATE TABLE users (\n\tid integer NOT NULL,\n\temail varchar(255) NOT NULL,\n\tage integer,\n\torg_id integer NOT NULL,\n\tprice decimal(10,2) NOT NULL DEFAULT 0,\n\tPRIMARY KEY (id),\n\tUNIQUE (email),\n\tFOREIGN KEY (org_id) REFERENCES orgs (id)\n)",
+			"CREATE INDEX idx_users_org_id ON users (org_id)",
+		}},
+		{"postgres", "code:string(20):pk,label:text:index", []string{
+			"CREATE TABLE users (\n\tcode varchar(20) NOT NULL,\n\tlabel text NOT NULL,\n\tCONSTRAINT users_pkey PRIMARY KEY (code)\n)",
+			"CREATE INDEX idx_users_label ON users (label)",
+		}},
+		{"mysql", "code:string(20):pk,label:text:index", []string{
+			"CREATE TABLE users (\n\tcode varchar(20) NOT NULL,\n\tlabel text NOT NULL,\n\tPRIMARY KEY (code)\n)",
+			"CREATE INDEX idx_users_label ON users (label)",
+		}},
+		{"sqlite", "id:uuid,owner:ref:accounts(code):null", []string{
+			"CREATE TABLE users (\n\tid text NOT NULL,\n\towner integer,\n\tPRIMARY KEY (id),\n\tFOREIGN KEY (owner) REFERENCES accounts (code)\n)",
+			"CREATE INDEX idx_users_owner ON users (owner)",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.dialect+" "+test.columns, func(t *testing.T) {
+			up, down, err := ScaffoldMigration("create_users_table", test.columns, nil, test.dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := strings.Join(up, ";\n"), strings.Join(test.want, ";\n"); got != want {
+				t.Errorf("up:\n%s\nwant:\n%s", got, want)
+			}
+			if strings.Join(down, ";\n") != "DROP TABLE users" {
+				t.Errorf("down %q", down)
+			}
+		})
+	}
+}
+
+func TestScaffoldCreateTableApplies(t *testing.T) {
+	up, down, err := ScaffoldMigration("create_users_table", "email:string:unique,age:int:null,org_id:ref:orgs:null", nil, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := openTestDB(t)
+	for _, stmt := range append([]string{"CREATE TABLE orgs (id integer PRIMARY KEY)"}, up...) {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %s", stmt, err)
+		}
+	}
+	if _, err := db.Exec("INSERT INTO users (email) VALUES ('a@example.com')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO users (email) VALUES ('a@example.com')"); err == nil {
+		t.Error("duplicate email inserted")
+	}
+	for _, stmt := range down {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %s", stmt, err)
+		}
+	}
+	if tableExists(t, db, "users") {
+		t.Error("down left the table")
+	}
+}
+
+func TestScaffoldColumnErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns string
+		dialect string
+		err     string
+	}{
+		{"create_users_table", " , ", "postgresql", `No columns in " , "`},
+		{"create_users_table", "email", "postgresql", `Invalid column "email": expected name:type`},
+		{"create_users_table", "email:", "mysql", `Invalid column "email:": expected name:type`},
+		{"create_users_table", "org_id:ref", "sqlite3", `Invalid column "org_id:ref": ref needs the referenced table, as ref:table`},
+		{"create_users_table", "age:int:bogus", "postgresql", `Invalid column "age:int:bogus": unknown modifier bogus`},
+		{"create_users_table", "age:int:default", "postgresql", `Invalid column "age:int:default": default needs a value, as default=expr`},
+		{"create_users_table", "age:int", "oracle", "Column scaffolding is not supported by dialect: oracle"},
+		{"drop_users_table", "age:int", "postgresql", "Columns can not be given to drop migrations"},
+		{"seed", "age:int", "postgresql", "Columns can not be given to seed, expected create_<table>_table, add_<column>_in_<table>_table or alter_<column>_in_<table>_table"},
+	}
+	for _, test := range tests {
+		t.Run(test.name+" "+test.columns, func(t *testing.T) {
+			_, _, err := ScaffoldMigration(test.name, test.columns, nil, test.dialect)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("got %v, want %s", err, test.err)
+			}
+		})
+	}
+}
+
+var scaffoldUsers = &Migration{
+	Id:   "1_create_users_table.sql",
+	Up:   []string{"CREATE TABLE users (id integer PRIMARY KEY, email varchar(255) NOT NULL, name text)"},
+	Down: []string{"DROP TABLE users"},
+}
+
+func TestScaffoldAlterTable(t *testing.T) {
+	tests := []struct {
+		dialect string
+		name    string
+		columns string
+		up      string
+		down    string
+		err     string
+	}{
+		{dialect: "postgresql", name: "add_age_in_users_table", up: "ALTER TABLE users ADD COLUMN age varchar(200)", down: "ALTER TABLE users DROP COLUMN age"},
+		{dialect: "postgresql", name: "add_age_in_users_table", columns: "age:int:default=0", up: "ALTER TABLE users ADD COLUMN age integer NOT NULL DEFAULT 0", down: "ALTER TABLE users DROP COLUMN age"},
+		{dialect: "postgresql", name: "remove_email_from_users_table", up: "ALTER TABLE users DROP COLUMN email", down: "ALTER TABLE users ADD COLUMN email varchar(255) NOT NULL"},
+		{
+			dialect: "postgresql", name: "alter_name_in_users_table", columns: "name:string(50)",
+			up:   "ALTER TABLE users ALTER COLUMN name TYPE varchar(50); ALTER TABLE users ALTER COLUMN name SET NOT NULL",
+			down: "ALTER TABLE users ALTER COLUMN name TYPE text; ALTER TABLE users ALTER COLUMN name DROP NOT NULL",
+		},
+		{dialect: "postgresql", name: "rename_name_to_full_name_in_users_table", up: "ALTER TABLE users RENAME COLUMN name TO full_name", down: "ALTER TABLE users RENAME COLUMN full_name TO name"},
+		{dialect: "postgresql", name: "rename_users_in_accounts_table", up: "ALTER TABLE users RENAME TO accounts", down: "ALTER TABLE accounts RENAME TO users"},
+		{dialect: "postgresql", name: "remove_title_from_posts_table", up: "ALTER TABLE posts DROP COLUMN title", down: "ALTER TABLE posts ADD COLUMN title varchar(200)"},
+		{dialect: "postgresql", name: "alter_title_in_posts_table", columns: "title:text", up: "ALTER TABLE posts ALTER COLUMN title TYPE text"},
+		{dialect: "mysql", name: "add_age_in_users_table", columns: "age:int:default=0", up: "ALTER TABLE users ADD COLUMN age int NOT NULL DEFAULT 0", down: "ALTER TABLE users DROP COLUMN age"},
+		{dialect: "mysql", name: "remove_email_from_users_table", up: "ALTER TABLE users DROP COLUMN email", down: "ALTER TABLE users ADD COLUMN email varchar(255) NOT NULL"},
+		{dialect: "mysql", name: "change_name_in_users_table", columns: "name:string(50)", up: "ALTER TABLE users MODIFY COLUMN name varchar(50) NOT NULL", down: "ALTER TABLE users MODIFY COLUMN name text"},
+		{dialect: "mysql", name: "alter_title_in_posts_table", columns: "title:text", up: "ALTER TABLE posts MODIFY COLUMN title text NOT NULL"},
+		{dialect: "sqlite3", name: "add_age_in_posts_table", up: "ALTER TABLE posts ADD COLUMN age varchar(200)", down: "ALTER TABLE posts DROP COLUMN age"},
+		{dialect: "sqlite3", name: "remove_title_from_posts_table", up: "ALTER TABLE posts DROP COLUMN title", down: "ALTER TABLE posts ADD COLUMN title varchar(200)"},
+		{dialect: "sqlite3", name: "rename_name_to_full_name_in_users_table", up: "ALTER TABLE users RENAME COLUMN name TO full_name", down: "ALTER TABLE users RENAME COLUMN full_name TO name"},
+		{dialect: "postgresql", name: "add_email_in_users_table", err: "Table users already has a column email"},
+		{dialect: "postgresql", name: "remove_age_from_users_table", err: "Table users has no column age"},
+		{dialect: "mysql", name: "alter_age_in_users_table", columns: "age:int", err: "Table users has no column age"},
+		{dialect: "mysql", name: "rename_age_to_years_in_users_table", err: "Table users has no column age"},
+		{dialect: "postgresql", name: "rename_posts_in_users_table", err: "Table users already exists"},
+		{dialect: "postgresql", name: "add_age_in_users_table", columns: "age:int:pk", err: `Invalid column "age:int:pk": the primary key of an existing table can not be changed`},
+		{dialect: "sqlite3", name: "alter_title_in_posts_table", columns: "title:text", err: "sqlite3 rebuilds table posts to change it, which needs its definition but no migration creates it"},
+		{dialect: "sqlite3", name: "add_title_in_posts_table", columns: "title:string", err: "sqlite3 rebuilds table posts to add a NOT NULL column without default or a constraint, which needs its definition but no migration creates it"},
+	}
+	current, err := ReplaySchema(MemoryMigrationSource{Migrations: []*Migration{scaffoldUsers}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.dialect+" "+test.name, func(t *testing.T) {
+			up, down, err := ScaffoldMigration(test.name, test.columns, current, test.dialect)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(up, "; "); got != test.up {
+				t.Errorf("up %s, want %s", got, test.up)
+			}
+			if got := strings.Join(down, "; "); got != test.down {
+				t.Errorf("down %s, want %s", got, test.down)
+			}
+		})
+	}
+}
+
+// TestScaffoldSqliteRebuild checks that sqlite3 rebuilds the tables created
+// by a migration, copying their rows.
+func TestScaffoldSqliteRebuild(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns string
+		up      string
+		down    string
+	}{
+		{"add_age_in_users_table", "", "", "INSERT INTO _users_new (id, email, name) SELECT id, email, name FROM users"},
+		{"remove_email_from_users_table", "", "INSERT INTO _users_new (id, name) SELECT id, name FROM users", "INSERT INTO _users_new (id, email, name) SELECT id, '', name FROM users"},
+		{"alter_name_in_users_table", "name:string(50)", "INSERT INTO _users_new (id, email, name) SELECT id, email, name FROM users", "INSERT INTO _users_new (id, email, name) SELECT id, email, name FROM users"},
+	}
+	current, err := ReplaySchema(MemoryMigrationSource{Migrations: []*Migration{scaffoldUsers}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			up, down, err := ScaffoldMigration(test.name, test.columns, current, "sqlite3")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, side := range []struct {
+				name   string
+				stmts  []string
+				insert string
+			}{{"up", up, test.up}, {"down", down, test.down}} {
+				if side.insert == "" {
+					if rebuildsTable(side.stmts, "users") {
+						t.Errorf("%s rebuilds the table: %q", side.name, side.stmts)
+					}
+					continue
+				}
+				if !rebuildsTable(side.stmts, "users") || !NeedsOwnTransaction(side.stmts) {
+					t.Errorf("%s does not rebuild the table: %q", side.name, side.stmts)
+				}
+				if !slices.Contains(side.stmts, side.insert) {
+					t.Errorf("%s does not copy the rows with %s: %q", side.name, side.insert, side.stmts)
+				}
+			}
+		})
+	}
+}
+
+// TestScaffoldSqliteRemoveRollback applies a scaffolded removal of a NOT
+// NULL column from a table holding rows, then rolls it back.
+func TestScaffoldSqliteRemoveRollback(t *testing.T) {
+	useMockUi(t)
+	db := openTestDB(t)
+	current, err := ReplaySchema(MemoryMigrationSource{Migrations: []*Migration{scaffoldUsers}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	up, down, err := ScaffoldMigration("remove_email_from_users_table", "", current, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := MemoryMigrationSource{Migrations: []*Migration{scaffoldUsers, {
+		Id:                     "2_remove_email_from_users_table.sql",
+		Up:                     up,
+		Down:                   down,
+		DisableTransactionUp:   NeedsOwnTransaction(up),
+		DisableTransactionDown: NeedsOwnTransaction(down),
+		AllowDestructive:       true,
+	}}}
+
+	ms := MigrationSet{}
+	if _, err := ms.ExecMax(db, "sqlite3", source, Up, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO users (id, email, name) VALUES (1, 'app@example.com', 'app')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ms.Exec(db, "sqlite3", source, Up); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ms.ExecMax(db, "sqlite3", source, Down, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var email, name string
+	if err := db.QueryRow("SELECT email, name FROM users WHERE id = 1").Scan(&email, &name); err != nil {
+		t.Fatal(err)
+	}
+	if email != "" || name != "app" {
+		t.Errorf("after down: email %q, name %q", email, name)
+	}
+	if _, err := db.Exec("INSERT INTO users (id, name) VALUES (2, 'other')"); err == nil {
+		t.Error("email is not NOT NULL after down")
+	}
+}
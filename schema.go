@@ -0,0 +1,691 @@
+package migration
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Schema is the introspected structure of a database, ordered
+// deterministically so two snapshots can be compared.
+type Schema struct {
+	Tables []*SchemaTable `json:"tables"`
+	Views  []*SchemaView  `json:"views"`
+}
+
+type SchemaTable struct {
+	Name        string              `json:"name"`
+	Columns     []*SchemaColumn     `json:"columns"`
+	Indexes     []*SchemaIndex      `json:"indexes"`
+	Constraints []*SchemaConstraint `json:"constraints"`
+}
+
+type SchemaColumn struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+	// Default expression, empty when the column has no default.
+	Default string `json:"default,omitempty"`
+}
+
+type SchemaIndex struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+}
+
+const (
+	ConstraintPrimaryKey = "PRIMARY KEY"
+	ConstraintUnique     = "UNIQUE"
+	ConstraintForeignKey = "FOREIGN KEY"
+	ConstraintCheck      = "CHECK"
+)
+
+type SchemaConstraint struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+	// Definition as it would appear in a CREATE TABLE, e.g.
+	// "FOREIGN KEY (org_id) REFERENCES orgs (id)".
+	Definition string `json:"definition"`
+}
+
+type SchemaView struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
+
+// Table returns the table with the given name, or nil.
+func (s *Schema) Table(name string) *SchemaTable {
+	for _, t := range s.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Column returns the column with the given name, or nil.
+func (t *SchemaTable) Column(name string) *SchemaColumn {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *Schema) table(name string) *SchemaTable {
+	if t := s.Table(name); t != nil {
+		return t
+	}
+	t := &SchemaTable{Name: name}
+	s.Tables = append(s.Tables, t)
+	return t
+}
+
+// sort orders tables, views, indexes and constraints by name. Columns keep
+// their ordinal position.
+func (s *Schema) sort() {
+	sort.Slice(s.Tables, func(i, j int) bool { return s.Tables[i].Name < s.Tables[j].Name })
+	sort.Slice(s.Views, func(i, j int) bool { return s.Views[i].Name < s.Views[j].Name })
+	for _, t := range s.Tables {
+		sort.Slice(t.Indexes, func(i, j int) bool { return t.Indexes[i].Name < t.Indexes[j].Name })
+		sort.Slice(t.Constraints, func(i, j int) bool {
+			a, b := t.Constraints[i], t.Constraints[j]
+			if a.Type != b.Type {
+				return a.Type < b.Type
+			}
+			return a.Definition < b.Definition
+		})
+	}
+}
+
+// Describe returns one line per schema object, in a stable order. Two
+// schemas are equal when their descriptions are.
+func (s *Schema) Describe() []string {
+	var lines []string
+	for _, t := range s.Tables {
+		lines = append(lines, "table "+t.Name)
+		for _, c := range t.Columns {
+			line := fmt.Sprintf("column %s.%s %s", t.Name, c.Name, c.Type)
+			if !c.Nullable {
+				line += " NOT NULL"
+			}
+			if c.Default != "" {
+				line += " DEFAULT " + c.Default
+			}
+			lines = append(lines, line)
+		}
+		for _, i := range t.Indexes {
+			kind := "index"
+			if i.Unique {
+				kind = "unique index"
+			}
+			lines = append(lines, fmt.Sprintf("%s %s.%s (%s)", kind, t.Name, i.Name, strings.Join(i.Columns, ", ")))
+		}
+		for _, c := range t.Constraints {
+			lines = append(lines, fmt.Sprintf("constraint %s %s", t.Name, c.Definition))
+		}
+	}
+	for _, v := range s.Views {
+		lines = append(lines, fmt.Sprintf("view %s AS %s", v.Name, strings.Join(strings.Fields(v.Definition), " ")))
+	}
+	return lines
+}
+
+// DiffSchemas returns the lines of the description of b that are not in a,
+// prefixed with "+", and the lines of a not in b, prefixed with "-".
+func DiffSchemas(a, b *Schema) []string {
+	before := make(map[string]bool)
+	for _, line := range a.Describe() {
+		before[line] = true
+	}
+	after := make(map[string]bool)
+	var diff []string
+	for _, line := range b.Describe() {
+		after[line] = true
+		if !before[line] {
+			diff = append(diff, "+ "+line)
+		}
+	}
+	for _, line := range a.Describe() {
+		if !after[line] {
+			diff = append(diff, "- "+line)
+		}
+	}
+	return diff
+}
+
+// WriteSQL writes the schema as canonical CREATE statements.
+func (s *Schema) WriteSQL(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("-- Code generated by migration. DO NOT EDIT.\n")
+	for _, t := range s.Tables {
+		fmt.Fprintf(&b, "\n%s;\n", t.CreateSQL())
+		for _, i := range t.Indexes {
+			fmt.Fprintf(&b, "%s;\n", i.CreateSQL(t.Name))
+		}
+	}
+	for _, v := range s.Views {
+		fmt.Fprintf(&b, "\n%s;\n", v.CreateSQL())
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// CreateSQL returns the CREATE TABLE statement of the table, without the
+// trailing semicolon.
+func (t *SchemaTable) CreateSQL() string {
+	var defs []string
+	for _, c := range t.Columns {
+		defs = append(defs, c.Definition())
+	}
+	for _, c := range t.Constraints {
+		if c.Name != "" {
+			defs = append(defs, "CONSTRAINT "+c.Name+" "+c.Definition)
+		} else {
+			defs = append(defs, c.Definition)
+		}
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n\t%s\n)", t.Name, strings.Join(defs, ",\n\t"))
+}
+
+// Definition returns the column as it appears in a CREATE TABLE.
+func (c *SchemaColumn) Definition() string {
+	def := c.Name + " " + c.Type
+	if !c.Nullable {
+		def += " NOT NULL"
+	}
+	if c.Default != "" {
+		def += " DEFAULT " + c.Default
+	}
+	return def
+}
+
+// CreateSQL returns the CREATE INDEX statement of the index.
+func (i *SchemaIndex) CreateSQL(table string) string {
+	unique := ""
+	if i.Unique {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique, i.Name, table, strings.Join(i.Columns, ", "))
+}
+
+// CreateSQL returns the CREATE VIEW statement of the view.
+func (v *SchemaView) CreateSQL() string {
+	definition := strings.TrimSuffix(strings.TrimSpace(v.Definition), ";")
+	if strings.HasPrefix(strings.ToUpper(definition), "CREATE") {
+		return definition
+	}
+	return fmt.Sprintf("CREATE VIEW %s AS\n%s", v.Name, definition)
+}
+
+// WriteJSON writes the schema as indented JSON.
+func (s *Schema) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// DumpSchema introspects the database and writes schema.sql and schema.json
+// into dir.
+func DumpSchema(db *sql.DB, dialect, dir string) error {
+	return migSet.DumpSchema(db, dialect, dir)
+}
+
+func (ms MigrationSet) DumpSchema(db *sql.DB, dialect, dir string) error {
+	s, err := ms.SnapshotSchema(db, dialect)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	for name, write := range map[string]func(io.Writer) error{
+		"schema.sql":  s.WriteSQL,
+		"schema.json": s.WriteJSON,
+	} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		if err := write(f); err != nil {
+			_ = f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SnapshotSchema introspects the schema of the database, leaving out the
+// migration tracking table.
+func SnapshotSchema(db *sql.DB, dialect string) (*Schema, error) {
+	return migSet.SnapshotSchema(db, dialect)
+}
+
+func (ms MigrationSet) SnapshotSchema(db *sql.DB, dialect string) (*Schema, error) {
+	dialect = CanonicalDialect(dialect)
+
+	s := &Schema{}
+	var err error
+	switch dialect {
+	case "sqlite3":
+		err = snapshotSqlite(db, s)
+	case "postgresql":
+		// The sets of a TenantRunner point search_path at the tenant
+		// schema, which is the one described.
+		schema := ""
+		if ms.SearchPath != "" {
+			schema = ms.SchemaName
+		}
+		err = snapshotPostgres(db, s, schema)
+	case "mysql":
+		err = snapshotMysql(db, s)
+	default:
+		err = fmt.Errorf("Schema introspection is not supported by dialect: %s", dialect)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tables := s.Tables[:0]
+	for _, t := range s.Tables {
+		if t.Name != ms.getTableName() {
+			tables = append(tables, t)
+		}
+	}
+	s.Tables = tables
+	s.sort()
+	return s, nil
+}
+
+// queryRows runs query and calls scan for every row.
+func queryRows(db *sql.DB, query string, scan func(*sql.Rows) error) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func snapshotSqlite(db *sql.DB, s *Schema) error {
+	var tables []string
+	definitions := make(map[string]string)
+	err := queryRows(db, "SELECT name, type, COALESCE(sql, '') FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%' ORDER BY name", func(rows *sql.Rows) error {
+		var name, kind, definition string
+		if err := rows.Scan(&name, &kind, &definition); err != nil {
+			return err
+		}
+		if kind == "view" {
+			s.Views = append(s.Views, &SchemaView{Name: name, Definition: definition})
+		} else {
+			tables = append(tables, name)
+			definitions[name] = definition
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range tables {
+		t := s.table(name)
+		quoted := `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+
+		var pk []string
+		err := queryRows(db, "PRAGMA table_info("+quoted+")", func(rows *sql.Rows) error {
+			var (
+				cid, notNull, pkIndex int
+				column, columnType    string
+				defaultValue          sql.NullString
+			)
+			if err := rows.Scan(&cid, &column, &columnType, &notNull, &defaultValue, &pkIndex); err != nil {
+				return err
+			}
+			t.Columns = append(t.Columns, &SchemaColumn{
+				Name:     column,
+				Type:     strings.ToLower(columnType),
+				Nullable: notNull == 0 && pkIndex == 0,
+				Default:  defaultValue.String,
+			})
+			if pkIndex > 0 {
+				for len(pk) < pkIndex {
+					pk = append(pk, "")
+				}
+				pk[pkIndex-1] = column
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(pk) > 0 {
+			t.Constraints = append(t.Constraints, &SchemaConstraint{
+				Type:       ConstraintPrimaryKey,
+				Definition: fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(pk, ", ")),
+			})
+		}
+
+		type indexInfo struct {
+			name   string
+			unique bool
+			origin string
+		}
+		var indexes []indexInfo
+		err = queryRows(db, "PRAGMA index_list("+quoted+")", func(rows *sql.Rows) error {
+			var (
+				seq, unique, partial int
+				index, origin        string
+			)
+			if err := rows.Scan(&seq, &index, &unique, &origin, &partial); err != nil {
+				return err
+			}
+			indexes = append(indexes, indexInfo{index, unique == 1, origin})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, index := range indexes {
+			var columns []string
+			err := queryRows(db, `PRAGMA index_info("`+strings.ReplaceAll(index.name, `"`, `""`)+`")`, func(rows *sql.Rows) error {
+				var seqno, cid int
+				var column sql.NullString
+				if err := rows.Scan(&seqno, &cid, &column); err != nil {
+					return err
+				}
+				columns = append(columns, column.String)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			switch index.origin {
+			case "pk":
+			case "u":
+				t.Constraints = append(t.Constraints, &SchemaConstraint{
+					Type:       ConstraintUnique,
+					Definition: fmt.Sprintf("UNIQUE (%s)", strings.Join(columns, ", ")),
+				})
+			default:
+				t.Indexes = append(t.Indexes, &SchemaIndex{Name: index.name, Columns: columns, Unique: index.unique})
+			}
+		}
+
+		foreignKeys := make(map[int]*[4][]string)
+		var ids []int
+		err = queryRows(db, "PRAGMA foreign_key_list("+quoted+")", func(rows *sql.Rows) error {
+			var (
+				id, seq                                int
+				table, from, onUpdate, onDelete, match string
+				to                                     sql.NullString
+			)
+			if err := rows.Scan(&id, &seq, &table, &from, &to, &onUpdate, &onDelete, &match); err != nil {
+				return err
+			}
+			fk, ok := foreignKeys[id]
+			if !ok {
+				actions := ""
+				if onUpdate != "NO ACTION" {
+					actions += " ON UPDATE " + onUpdate
+				}
+				if onDelete != "NO ACTION" {
+					actions += " ON DELETE " + onDelete
+				}
+				fk = &[4][]string{{table}, nil, nil, {actions}}
+				foreignKeys[id] = fk
+				ids = append(ids, id)
+			}
+			fk[1] = append(fk[1], from)
+			// to is NULL when the key references the primary key of the
+			// parent table implicitly.
+			if to.Valid {
+				fk[2] = append(fk[2], to.String)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			fk := foreignKeys[id]
+			references := fk[0][0]
+			if len(fk[2]) > 0 {
+				references += " (" + strings.Join(fk[2], ", ") + ")"
+			}
+			t.Constraints = append(t.Constraints, &SchemaConstraint{
+				Type:       ConstraintForeignKey,
+				Definition: fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s%s", strings.Join(fk[1], ", "), references, fk[3][0]),
+			})
+		}
+
+		// No PRAGMA lists CHECK constraints, they are only kept in the
+		// CREATE TABLE statement.
+		parsed := &Schema{}
+		if err := parsed.ApplyStatement(definitions[name]); err != nil {
+			return err
+		}
+		if created := parsed.Table(name); created != nil {
+			for _, c := range created.Constraints {
+				if c.Type == ConstraintCheck {
+					t.Constraints = append(t.Constraints, c)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+var postgresConstraintTypes = map[string]string{
+	"p": ConstraintPrimaryKey,
+	"u": ConstraintUnique,
+	"f": ConstraintForeignKey,
+	"c": ConstraintCheck,
+}
+
+// snapshotPostgres describes the tables and views of schema, or of the
+// current schema when empty.
+func snapshotPostgres(db *sql.DB, s *Schema, schema string) error {
+	namespace := "current_schema()"
+	if schema != "" {
+		namespace = "'" + strings.ReplaceAll(schema, "'", "''") + "'"
+	}
+	err := queryRows(db, `SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull, COALESCE(pg_get_expr(d.adbin, d.adrelid), '')
+FROM pg_attribute a
+JOIN pg_class c ON c.oid = a.attrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+WHERE n.nspname = `+namespace+` AND c.relkind IN ('r', 'p') AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY c.relname, a.attnum`, func(rows *sql.Rows) error {
+		var table, column, columnType, defaultValue string
+		var notNull bool
+		if err := rows.Scan(&table, &column, &columnType, &notNull, &defaultValue); err != nil {
+			return err
+		}
+		t := s.table(table)
+		t.Columns = append(t.Columns, &SchemaColumn{
+			Name:     column,
+			Type:     columnType,
+			Nullable: !notNull,
+			Default:  defaultValue,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = queryRows(db, `SELECT t.relname, i.relname, ix.indisunique,
+	array_to_string(ARRAY(SELECT pg_get_indexdef(ix.indexrelid, k.ord::int, true) FROM generate_series(1, ix.indnkeyatts) AS k(ord) ORDER BY k.ord), ',')
+FROM pg_index ix
+JOIN pg_class i ON i.oid = ix.indexrelid
+JOIN pg_class t ON t.oid = ix.indrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+WHERE n.nspname = `+namespace+`
+AND NOT EXISTS (SELECT 1 FROM pg_constraint con WHERE con.conindid = ix.indexrelid AND con.contype IN ('p', 'u', 'x'))
+ORDER BY t.relname, i.relname`, func(rows *sql.Rows) error {
+		var table, index, columns string
+		var unique bool
+		if err := rows.Scan(&table, &index, &unique, &columns); err != nil {
+			return err
+		}
+		t := s.table(table)
+		t.Indexes = append(t.Indexes, &SchemaIndex{Name: index, Columns: strings.Split(columns, ","), Unique: unique})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = queryRows(db, `SELECT t.relname, con.conname, con.contype, pg_get_constraintdef(con.oid, true)
+FROM pg_constraint con
+JOIN pg_class t ON t.oid = con.conrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+WHERE n.nspname = `+namespace+` AND con.contype IN ('p', 'u', 'f', 'c')
+ORDER BY t.relname, con.conname`, func(rows *sql.Rows) error {
+		var table, name, kind, definition string
+		if err := rows.Scan(&table, &name, &kind, &definition); err != nil {
+			return err
+		}
+		t := s.table(table)
+		t.Constraints = append(t.Constraints, &SchemaConstraint{Name: name, Type: postgresConstraintTypes[kind], Definition: definition})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return queryRows(db, "SELECT viewname, definition FROM pg_views WHERE schemaname = "+namespace+" ORDER BY viewname", func(rows *sql.Rows) error {
+		var name, definition string
+		if err := rows.Scan(&name, &definition); err != nil {
+			return err
+		}
+		s.Views = append(s.Views, &SchemaView{Name: name, Definition: definition})
+		return nil
+	})
+}
+
+func snapshotMysql(db *sql.DB, s *Schema) error {
+	err := queryRows(db, `SELECT c.TABLE_NAME, c.COLUMN_NAME, c.COLUMN_TYPE, c.IS_NULLABLE, c.COLUMN_DEFAULT
+FROM information_schema.COLUMNS c
+JOIN information_schema.TABLES t ON t.TABLE_SCHEMA = c.TABLE_SCHEMA AND t.TABLE_NAME = c.TABLE_NAME
+WHERE c.TABLE_SCHEMA = DATABASE() AND t.TABLE_TYPE = 'BASE TABLE'
+ORDER BY c.TABLE_NAME, c.ORDINAL_POSITION`, func(rows *sql.Rows) error {
+		var table, column, columnType, nullable string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&table, &column, &columnType, &nullable, &defaultValue); err != nil {
+			return err
+		}
+		t := s.table(table)
+		t.Columns = append(t.Columns, &SchemaColumn{
+			Name:     column,
+			Type:     columnType,
+			Nullable: nullable == "YES",
+			Default:  defaultValue.String,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Unique and primary keys are reported as constraints below.
+	err = queryRows(db, `SELECT s.TABLE_NAME, s.INDEX_NAME, s.NON_UNIQUE, GROUP_CONCAT(s.COLUMN_NAME ORDER BY s.SEQ_IN_INDEX)
+FROM information_schema.STATISTICS s
+WHERE s.TABLE_SCHEMA = DATABASE() AND NOT EXISTS (
+	SELECT 1 FROM information_schema.TABLE_CONSTRAINTS tc
+	WHERE tc.TABLE_SCHEMA = s.TABLE_SCHEMA AND tc.TABLE_NAME = s.TABLE_NAME AND tc.CONSTRAINT_NAME = s.INDEX_NAME
+	AND tc.CONSTRAINT_TYPE IN ('PRIMARY KEY', 'UNIQUE'))
+GROUP BY s.TABLE_NAME, s.INDEX_NAME, s.NON_UNIQUE
+ORDER BY s.TABLE_NAME, s.INDEX_NAME`, func(rows *sql.Rows) error {
+		var table, index, columns string
+		var nonUnique int
+		if err := rows.Scan(&table, &index, &nonUnique, &columns); err != nil {
+			return err
+		}
+		t := s.table(table)
+		t.Indexes = append(t.Indexes, &SchemaIndex{Name: index, Columns: strings.Split(columns, ","), Unique: nonUnique == 0})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = queryRows(db, `SELECT tc.TABLE_NAME, tc.CONSTRAINT_NAME, tc.CONSTRAINT_TYPE,
+	GROUP_CONCAT(k.COLUMN_NAME ORDER BY k.ORDINAL_POSITION),
+	COALESCE(MAX(k.REFERENCED_TABLE_NAME), ''),
+	COALESCE(GROUP_CONCAT(k.REFERENCED_COLUMN_NAME ORDER BY k.ORDINAL_POSITION), '')
+FROM information_schema.TABLE_CONSTRAINTS tc
+JOIN information_schema.KEY_COLUMN_USAGE k ON k.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA AND k.TABLE_NAME = tc.TABLE_NAME AND k.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+WHERE tc.TABLE_SCHEMA = DATABASE() AND tc.CONSTRAINT_TYPE IN ('PRIMARY KEY', 'UNIQUE', 'FOREIGN KEY')
+GROUP BY tc.TABLE_NAME, tc.CONSTRAINT_NAME, tc.CONSTRAINT_TYPE
+ORDER BY tc.TABLE_NAME, tc.CONSTRAINT_NAME`, func(rows *sql.Rows) error {
+		var table, name, kind, columns, refTable, refColumns string
+		if err := rows.Scan(&table, &name, &kind, &columns, &refTable, &refColumns); err != nil {
+			return err
+		}
+		columns = strings.ReplaceAll(columns, ",", ", ")
+		definition := fmt.Sprintf("%s (%s)", kind, columns)
+		if kind == ConstraintForeignKey {
+			definition += fmt.Sprintf(" REFERENCES %s (%s)", refTable, strings.ReplaceAll(refColumns, ",", ", "))
+		}
+		if kind == ConstraintPrimaryKey {
+			name = ""
+		}
+		t := s.table(table)
+		t.Constraints = append(t.Constraints, &SchemaConstraint{Name: name, Type: kind, Definition: definition})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// CHECK constraints are kept since MySQL 8.0.16, before which they are
+	// parsed and ignored.
+	var checks int
+	err = db.QueryRow("SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = 'information_schema' AND TABLE_NAME = 'CHECK_CONSTRAINTS'").Scan(&checks)
+	if err != nil {
+		return err
+	}
+	if checks > 0 {
+		err = queryRows(db, `SELECT tc.TABLE_NAME, cc.CONSTRAINT_NAME, cc.CHECK_CLAUSE
+FROM information_schema.CHECK_CONSTRAINTS cc
+JOIN information_schema.TABLE_CONSTRAINTS tc ON tc.CONSTRAINT_SCHEMA = cc.CONSTRAINT_SCHEMA AND tc.CONSTRAINT_NAME = cc.CONSTRAINT_NAME
+WHERE cc.CONSTRAINT_SCHEMA = DATABASE() AND tc.CONSTRAINT_TYPE = 'CHECK'
+ORDER BY tc.TABLE_NAME, cc.CONSTRAINT_NAME`, func(rows *sql.Rows) error {
+			var table, name, clause string
+			if err := rows.Scan(&table, &name, &clause); err != nil {
+				return err
+			}
+			t := s.table(table)
+			t.Constraints = append(t.Constraints, &SchemaConstraint{Name: name, Type: ConstraintCheck, Definition: "CHECK (" + clause + ")"})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return queryRows(db, "SELECT TABLE_NAME, VIEW_DEFINITION FROM information_schema.VIEWS WHERE TABLE_SCHEMA = DATABASE() ORDER BY TABLE_NAME", func(rows *sql.Rows) error {
+		var name, definition string
+		if err := rows.Scan(&name, &definition); err != nil {
+			return err
+		}
+		s.Views = append(s.Views, &SchemaView{Name: name, Definition: definition})
+		return nil
+	})
+}
@@ -0,0 +1,469 @@
+package migration
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	referencesTableRegex = regexp.MustCompile(`(?i)\bREFERENCES\s+(\S+)`)
+	typeCastRegex        = regexp.MustCompile(`::[\w ]+(\[\])?`)
+)
+
+// typeAliases maps spellings of the same column type onto one name, so
+// that a schema file and an introspected database compare equal.
+var typeAliases = map[string]string{
+	"int":                         "integer",
+	"int4":                        "integer",
+	"serial":                      "integer",
+	"serial4":                     "integer",
+	"int8":                        "bigint",
+	"bigserial":                   "bigint",
+	"serial8":                     "bigint",
+	"int2":                        "smallint",
+	"smallserial":                 "smallint",
+	"bool":                        "boolean",
+	"character varying":           "varchar",
+	"character":                   "char",
+	"timestamp without time zone": "timestamp",
+	"timestamp with time zone":    "timestamptz",
+	"time without time zone":      "time",
+	"double precision":            "double",
+	"float8":                      "double",
+	"float4":                      "real",
+	"decimal":                     "numeric",
+}
+
+// integerTypes ignore their mysql display width, e.g. int(11).
+var integerTypes = map[string]bool{
+	"integer":   true,
+	"bigint":    true,
+	"smallint":  true,
+	"tinyint":   true,
+	"mediumint": true,
+}
+
+// SchemaChanges returns the statements that turn schema from into schema to
+// with the given dialect. Swapping the arguments gives the statements that
+// revert them.
+//
+// Renames can not be told apart from a drop followed by a create and are
+// generated as such.
+func SchemaChanges(from, to *Schema, dialect string) ([]string, error) {
+	dialect = CanonicalDialect(dialect)
+
+	switch dialect {
+	case "sqlite3", "postgresql", "mysql":
+	default:
+		return nil, fmt.Errorf("Schema diff is not supported by dialect: %s", dialect)
+	}
+	d := &schemaDiffer{dialect: dialect}
+
+	for _, view := range from.Views {
+		if other := to.view(view.Name); other == nil || viewKey(other) != viewKey(view) {
+			d.add("DROP VIEW %s", view.Name)
+		}
+	}
+
+	var created, dropped []*SchemaTable
+	for _, t := range to.Tables {
+		if from.Table(t.Name) == nil {
+			created = append(created, t)
+		}
+	}
+	for _, t := range sortByReferences(created) {
+		d.add("%s", d.named(t).CreateSQL())
+		for _, index := range t.Indexes {
+			d.add("%s", index.CreateSQL(t.Name))
+		}
+	}
+
+	for _, t := range from.Tables {
+		if other := to.Table(t.Name); other != nil {
+			d.alterTable(t, other)
+		} else {
+			dropped = append(dropped, t)
+		}
+	}
+	ordered := sortByReferences(dropped)
+	for i := len(ordered) - 1; i >= 0; i-- {
+		d.add("DROP TABLE %s", ordered[i].Name)
+	}
+
+	for _, view := range to.Views {
+		if other := from.view(view.Name); other == nil || viewKey(other) != viewKey(view) {
+			d.add("%s", view.CreateSQL())
+		}
+	}
+
+	if d.rebuilt {
+		// sqlite's procedure for changing a table: foreign keys are turned
+		// off, which only works outside a transaction, so that dropping the
+		// old table neither fails nor cascades, checked before the commit
+		// and turned back on. The migration must run without a transaction
+		// of its own, see NeedsOwnTransaction.
+		stmts := []string{sqliteForeignKeysOff, "BEGIN"}
+		stmts = append(stmts, d.stmts...)
+		d.stmts = append(stmts, sqliteForeignKeyCheck, "COMMIT", sqliteForeignKeysOn)
+	}
+	return d.stmts, nil
+}
+
+const (
+	sqliteForeignKeysOff  = "PRAGMA foreign_keys = OFF"
+	sqliteForeignKeysOn   = "PRAGMA foreign_keys = ON"
+	sqliteForeignKeyCheck = "PRAGMA foreign_key_check"
+)
+
+// NeedsOwnTransaction tells whether statements returned by SchemaChanges
+// manage the transaction themselves, in which case the migration running
+// them is marked notransaction.
+func NeedsOwnTransaction(stmts []string) bool {
+	return slices.Contains(stmts, sqliteForeignKeysOff)
+}
+
+type schemaDiffer struct {
+	dialect string
+	stmts   []string
+	// rebuilt is set once a sqlite3 table is rebuilt.
+	rebuilt bool
+}
+
+func (d *schemaDiffer) add(format string, args ...interface{}) {
+	d.stmts = append(d.stmts, fmt.Sprintf(format, args...))
+}
+
+func (d *schemaDiffer) alterTable(from, to *SchemaTable) {
+	var added, dropped, changed []*SchemaColumn
+	for _, c := range to.Columns {
+		other := from.Column(c.Name)
+		switch {
+		case other == nil:
+			added = append(added, c)
+		case !columnsEqual(other, c):
+			changed = append(changed, c)
+		}
+	}
+	for _, c := range from.Columns {
+		if to.Column(c.Name) == nil {
+			dropped = append(dropped, c)
+		}
+	}
+
+	addedConstraints := constraintsMissing(to.Constraints, from.Constraints)
+	droppedConstraints := constraintsMissing(from.Constraints, to.Constraints)
+
+	var addedIndexes, droppedIndexes []*SchemaIndex
+	for _, index := range to.Indexes {
+		if other := from.index(index.Name); other == nil || indexKey(other) != indexKey(index) {
+			addedIndexes = append(addedIndexes, index)
+		}
+	}
+	for _, index := range from.Indexes {
+		if other := to.index(index.Name); other == nil || indexKey(other) != indexKey(index) {
+			droppedIndexes = append(droppedIndexes, index)
+		}
+	}
+
+	if d.dialect == "sqlite3" {
+		rebuild := len(changed) > 0 || len(dropped) > 0 || len(addedConstraints) > 0 || len(droppedConstraints) > 0
+		for _, c := range added {
+			// sqlite can only add a NOT NULL column that has a default.
+			rebuild = rebuild || (!c.Nullable && c.Default == "")
+		}
+		if rebuild {
+			d.rebuildTable(from, to)
+			return
+		}
+	}
+
+	for _, index := range droppedIndexes {
+		if d.dialect == "mysql" {
+			d.add("DROP INDEX %s ON %s", index.Name, from.Name)
+		} else {
+			d.add("DROP INDEX %s", index.Name)
+		}
+	}
+	for _, c := range droppedConstraints {
+		d.add("ALTER TABLE %s %s", from.Name, d.dropConstraint(from, c))
+	}
+	for _, c := range dropped {
+		d.add("ALTER TABLE %s DROP COLUMN %s", from.Name, c.Name)
+	}
+	for _, c := range added {
+		d.add("ALTER TABLE %s ADD COLUMN %s", to.Name, c.Definition())
+	}
+	for _, c := range changed {
+		d.alterColumn(to.Name, from.Column(c.Name), c)
+	}
+	for _, c := range addedConstraints {
+		d.add("ALTER TABLE %s ADD CONSTRAINT %s %s", to.Name, d.constraintName(to, c), c.Definition)
+	}
+	for _, index := range addedIndexes {
+		d.add("%s", index.CreateSQL(to.Name))
+	}
+}
+
+func (d *schemaDiffer) alterColumn(table string, from, to *SchemaColumn) {
+	if d.dialect == "mysql" {
+		d.add("ALTER TABLE %s MODIFY COLUMN %s", table, to.Definition())
+		return
+	}
+
+	if normalizeType(from.Type) != normalizeType(to.Type) {
+		d.add("ALTER TABLE %s ALTER COLUMN %s TYPE %s", table, to.Name, to.Type)
+	}
+	if from.Nullable != to.Nullable {
+		if to.Nullable {
+			d.add("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL", table, to.Name)
+		} else {
+			d.add("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", table, to.Name)
+		}
+	}
+	if normalizeDefault(from.Default) != normalizeDefault(to.Default) {
+		if to.Default == "" {
+			d.add("ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT", table, to.Name)
+		} else {
+			d.add("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s", table, to.Name, to.Default)
+		}
+	}
+}
+
+// rebuildTable recreates a sqlite3 table, which supports few ALTER TABLE
+// actions, copying the columns both versions have in common. The rows get
+// the zero value of the added NOT NULL columns without default, such as a
+// column dropped by Up and restored by Down, which would fail otherwise.
+func (d *schemaDiffer) rebuildTable(from, to *SchemaTable) {
+	d.rebuilt = true
+	var columns, values []string
+	for _, c := range to.Columns {
+		switch {
+		case from.Column(c.Name) != nil:
+			columns = append(columns, c.Name)
+			values = append(values, c.Name)
+		case !c.Nullable && c.Default == "":
+			columns = append(columns, c.Name)
+			values = append(values, sqliteZeroValue(c.Type))
+		}
+	}
+
+	rebuilt := *to
+	rebuilt.Name = "_" + to.Name + "_new"
+	d.add("%s", rebuilt.CreateSQL())
+	if len(columns) > 0 {
+		d.add("INSERT INTO %s (%s) SELECT %s FROM %s", rebuilt.Name, strings.Join(columns, ", "), strings.Join(values, ", "), from.Name)
+	}
+	d.add("DROP TABLE %s", from.Name)
+	d.add("ALTER TABLE %s RENAME TO %s", rebuilt.Name, to.Name)
+	for _, index := range to.Indexes {
+		d.add("%s", index.CreateSQL(to.Name))
+	}
+}
+
+// sqliteZeroValue returns the zero value of a column type, following the
+// rules sqlite3 uses to determine the affinity of a column.
+func sqliteZeroValue(columnType string) string {
+	t := strings.ToUpper(columnType)
+	switch {
+	case strings.Contains(t, "INT"):
+		return "0"
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return "''"
+	case t == "", strings.Contains(t, "BLOB"):
+		return "X''"
+	default:
+		return "0"
+	}
+}
+
+func (d *schemaDiffer) dropConstraint(t *SchemaTable, c *SchemaConstraint) string {
+	if d.dialect == "mysql" {
+		switch c.Type {
+		case ConstraintPrimaryKey:
+			return "DROP PRIMARY KEY"
+		case ConstraintForeignKey:
+			return "DROP FOREIGN KEY " + d.constraintName(t, c)
+		case ConstraintUnique:
+			return "DROP INDEX " + d.constraintName(t, c)
+		case ConstraintCheck:
+			return "DROP CHECK " + d.constraintName(t, c)
+		}
+	}
+	return "DROP CONSTRAINT " + d.constraintName(t, c)
+}
+
+// constraintName returns the name of the constraint, or the one postgresql
+// would give it when it was declared without a name.
+func (d *schemaDiffer) constraintName(t *SchemaTable, c *SchemaConstraint) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	columns := strings.Join(constraintColumns(c), "_")
+	switch c.Type {
+	case ConstraintPrimaryKey:
+		return t.Name + "_pkey"
+	case ConstraintUnique:
+		if d.dialect == "mysql" {
+			return constraintColumns(c)[0]
+		}
+		return t.Name + "_" + columns + "_key"
+	case ConstraintForeignKey:
+		return t.Name + "_" + columns + "_fkey"
+	default:
+		return t.Name + "_check"
+	}
+}
+
+// named returns a copy of the table in which every constraint has a name,
+// so that later migrations can drop them.
+func (d *schemaDiffer) named(t *SchemaTable) *SchemaTable {
+	if d.dialect == "sqlite3" {
+		return t
+	}
+	named := *t
+	named.Constraints = nil
+	for _, c := range t.Constraints {
+		copied := *c
+		if copied.Type != ConstraintPrimaryKey || d.dialect != "mysql" {
+			copied.Name = d.constraintName(t, c)
+		}
+		named.Constraints = append(named.Constraints, &copied)
+	}
+	return &named
+}
+
+func (s *Schema) view(name string) *SchemaView {
+	for _, v := range s.Views {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+func (t *SchemaTable) index(name string) *SchemaIndex {
+	for _, i := range t.Indexes {
+		if i.Name == name {
+			return i
+		}
+	}
+	return nil
+}
+
+// sortByReferences orders tables so that referenced tables come first.
+func sortByReferences(tables []*SchemaTable) []*SchemaTable {
+	pending := make(map[string]bool)
+	for _, t := range tables {
+		pending[t.Name] = true
+	}
+
+	var sorted []*SchemaTable
+	for len(sorted) < len(tables) {
+		progress := false
+		for _, t := range tables {
+			if !pending[t.Name] {
+				continue
+			}
+			ready := true
+			for _, c := range t.Constraints {
+				if match := referencesTableRegex.FindStringSubmatch(c.Definition); match != nil && match[1] != t.Name && pending[match[1]] {
+					ready = false
+				}
+			}
+			if ready {
+				sorted = append(sorted, t)
+				pending[t.Name] = false
+				progress = true
+			}
+		}
+		if !progress {
+			// Circular references, keep the remaining tables in order.
+			for _, t := range tables {
+				if pending[t.Name] {
+					sorted = append(sorted, t)
+					pending[t.Name] = false
+				}
+			}
+		}
+	}
+	return sorted
+}
+
+func constraintsMissing(constraints, in []*SchemaConstraint) []*SchemaConstraint {
+	keys := make(map[string]bool)
+	for _, c := range in {
+		keys[constraintKey(c)] = true
+	}
+	var missing []*SchemaConstraint
+	for _, c := range constraints {
+		if !keys[constraintKey(c)] {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+func columnsEqual(a, b *SchemaColumn) bool {
+	return normalizeType(a.Type) == normalizeType(b.Type) &&
+		a.Nullable == b.Nullable &&
+		normalizeDefault(a.Default) == normalizeDefault(b.Default)
+}
+
+func normalizeType(t string) string {
+	t = strings.ToLower(strings.Join(strings.Fields(t), " "))
+	base, args := t, ""
+	if i := strings.Index(t, "("); i >= 0 {
+		base, args = strings.TrimSpace(t[:i]), strings.ReplaceAll(t[i:], " ", "")
+	}
+	if alias, ok := typeAliases[base]; ok {
+		base = alias
+	}
+	if integerTypes[base] {
+		args = ""
+	}
+	return base + args
+}
+
+func normalizeDefault(value string) string {
+	value = strings.TrimSpace(value)
+	for strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		value = strings.TrimSpace(value[1 : len(value)-1])
+	}
+	value = strings.ToLower(typeCastRegex.ReplaceAllString(value, ""))
+	// Defaults of serial columns.
+	if value == "null" || strings.HasPrefix(value, "nextval(") {
+		return ""
+	}
+	return value
+}
+
+// constraintKey identifies a constraint by its definition, regardless of
+// its name and formatting.
+func constraintKey(c *SchemaConstraint) string {
+	return c.Type + ":" + compactSQL(c.Definition)
+}
+
+func indexKey(i *SchemaIndex) string {
+	return fmt.Sprintf("%t:%s", i.Unique, compactSQL(strings.Join(i.Columns, ",")))
+}
+
+func viewKey(v *SchemaView) string {
+	definition := strings.TrimSuffix(strings.TrimSpace(v.Definition), ";")
+	if match := ddlCreateViewRegex.FindStringSubmatch(definition); match != nil {
+		definition = match[2]
+	}
+	return compactSQL(definition)
+}
+
+// compactSQL lower-cases s and drops whitespace and identifier quotes.
+func compactSQL(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\n', '\r', '"', '`':
+			return -1
+		}
+		return r
+	}, strings.ToLower(s))
+}
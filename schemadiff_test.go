@@ -0,0 +1,228 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+)
+
+// parseSchema returns the schema the statements create.
+func parseSchema(t *testing.T, stmts ...string) *Schema {
+	t.Helper()
+	s := &Schema{}
+	for _, stmt := range stmts {
+		if err := s.ApplyStatement(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestSchemaChanges(t *testing.T) {
+	from := parseSchema(t,
+		"CREATE TABLE users (id integer PRIMARY KEY, name varchar(50) NOT NULL, email text)",
+		"CREATE TABLE old_logs (id integer PRIMARY KEY)",
+		"CREATE INDEX users_email ON users (email)",
+	)
+	to := parseSchema(t,
+		"CREATE TABLE users (id integer PRIMARY KEY, name varchar(100) NOT NULL, age integer NOT NULL DEFAULT 0, CONSTRAINT users_age CHECK (age >= 0))",
+		"CREATE TABLE posts (id integer PRIMARY KEY, user_id integer NOT NULL REFERENCES users (id), title text NOT NULL)",
+		"CREATE INDEX posts_user_id ON posts (user_id)",
+	)
+
+	tests := []struct {
+		dialect  string
+		up, down []string
+	}{
+		{
+			dialect: "postgresql",
+			up: []string{
+				"CREATE TABLE posts (\n\tid integer NOT NULL,\n\tuser_id integer NOT NULL,\n\ttitle text NOT NULL,\n\tCONSTRAINT posts_pkey PRIMARY KEY (id),\n\tCONSTRAINT posts_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id)\n)",
+				"CREATE INDEX posts_user_id ON posts (user_id)",
+				"DROP INDEX users_email",
+				"ALTER TABLE users DROP COLUMN email",
+				"ALTER TABLE users ADD COLUMN age integer NOT NULL DEFAULT 0",
+				"ALTER TABLE users ALTER COLUMN name TYPE varchar(100)",
+				"ALTER TABLE users ADD CONSTRAINT users_age CHECK (age >= 0)",
+				"DROP TABLE old_logs",
+			},
+			down: []string{
+				"CREATE TABLE old_logs (\n\tid integer NOT NULL,\n\tCONSTRAINT old_logs_pkey PRIMARY KEY (id)\n)",
+				"ALTER TABLE users DROP CONSTRAINT users_age",
+				"ALTER TABLE users DROP COLUMN age",
+				"ALTER TABLE users ADD COLUMN email text",
+				"ALTER TABLE users ALTER COLUMN name TYPE varchar(50)",
+				"CREATE INDEX users_email ON users (email)",
+				"DROP TABLE posts",
+			},
+		},
+		{
+			dialect: "mysql",
+			up: []string{
+				"CREATE TABLE posts (\n\tid integer NOT NULL,\n\tuser_id integer NOT NULL,\n\ttitle text NOT NULL,\n\tPRIMARY KEY (id),\n\tCONSTRAINT posts_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id)\n)",
+				"CREATE INDEX posts_user_id ON posts (user_id)",
+				"DROP INDEX users_email ON users",
+				"ALTER TABLE users DROP COLUMN email",
+				"ALTER TABLE users ADD COLUMN age integer NOT NULL DEFAULT 0",
+				"ALTER TABLE users MODIFY COLUMN name varchar(100) NOT NULL",
+				"ALTER TABLE users ADD CONSTRAINT users_age CHECK (age >= 0)",
+				"DROP TABLE old_logs",
+			},
+			down: []string{
+				"CREATE TABLE old_logs (\n\tid integer NOT NULL,\n\tPRIMARY KEY (id)\n)",
+				"ALTER TABLE users DROP CHECK users_age",
+				"ALTER TABLE users DROP COLUMN age",
+				"ALTER TABLE users ADD COLUMN email text",
+				"ALTER TABLE users MODIFY COLUMN name varchar(50) NOT NULL",
+				"CREATE INDEX users_email ON users (email)",
+				"DROP TABLE posts",
+			},
+		},
+		{
+			dialect: "sqlite3",
+			up: []string{
+				"PRAGMA foreign_keys = OFF",
+				"BEGIN",
+				"CREATE TABLE posts (\n\tid integer NOT NULL,\n\tuser_id integer NOT NULL,\n\ttitle text NOT NULL,\n\tPRIMARY KEY (id),\n\tFOREIGN KEY (user_id) REFERENCES users (id)\n)",
+				"CREATE INDEX posts_user_id ON posts (user_id)",
+				"CREATE TABLE _users_new (\n\tid integer NOT NULL,\n\tname varchar(100) NOT NULL,\n\tage integer NOT NULL DEFAULT 0,\n\tPRIMARY KEY (id),\n\tCONSTRAINT users_age CHECK (age >= 0)\n)",
+				"INSERT INTO _users_new (id, name) SELECT id, name FROM users",
+				"DROP TABLE users",
+				"ALTER TABLE _users_new RENAME TO users",
+				"DROP TABLE old_logs",
+				"PRAGMA foreign_key_check",
+				"COMMIT",
+				"PRAGMA foreign_keys = ON",
+			},
+			down: []string{
+				"PRAGMA foreign_keys = OFF",
+				"BEGIN",
+				"CREATE TABLE old_logs (\n\tid integer NOT NULL,\n\tPRIMARY KEY (id)\n)",
+				"CREATE TABLE _users_new (\n\tid integer NOT NULL,\n\tname varchar(50) NOT NULL,\n\temail text,\n\tPRIMARY KEY (id)\n)",
+				"INSERT INTO _users_new (id, name) SELECT id, name FROM users",
+				"DROP TABLE users",
+				"ALTER TABLE _users_new RENAME TO users",
+				"CREATE INDEX users_email ON users (email)",
+				"DROP TABLE posts",
+				"PRAGMA foreign_key_check",
+				"COMMIT",
+				"PRAGMA foreign_keys = ON",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.dialect, func(t *testing.T) {
+			up, err := SchemaChanges(from, to, test.dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			down, err := SchemaChanges(to, from, test.dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range []struct {
+				name      string
+				got, want []string
+			}{{"up", up, test.up}, {"down", down, test.down}} {
+				if strings.Join(s.got, ";\n") != strings.Join(s.want, ";\n") {
+					t.Errorf("%s:\n%s\nwant:\n%s", s.name, strings.Join(s.got, ";\n"), strings.Join(s.want, ";\n"))
+				}
+			}
+			if NeedsOwnTransaction(up) != (test.dialect == "sqlite3") {
+				t.Errorf("NeedsOwnTransaction = %v", NeedsOwnTransaction(up))
+			}
+		})
+	}
+
+	if stmts, err := SchemaChanges(to, to, "postgresql"); err != nil || len(stmts) != 0 {
+		t.Errorf("SchemaChanges of equal schemas = %q, %v", stmts, err)
+	}
+	if _, err := SchemaChanges(from, to, "oracle"); err == nil || err.Error() != "Schema diff is not supported by dialect: oracle" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestSchemaChangesEquivalentTypes(t *testing.T) {
+	from := parseSchema(t, "CREATE TABLE users (id int4 NOT NULL, name character varying(50), created_at timestamp without time zone, score float8)")
+	to := parseSchema(t, "CREATE TABLE users (id integer NOT NULL, name varchar(50), created_at timestamp, score double precision)")
+	if stmts, err := SchemaChanges(from, to, "postgresql"); err != nil || len(stmts) != 0 {
+		t.Errorf("got %q, %v", stmts, err)
+	}
+}
+
+// TestSchemaChangesSqliteRoundTrip applies the statements to a database
+// holding rows: Up drops a NOT NULL column, which Down restores with the
+// zero value of its type.
+func TestSchemaChangesSqliteRoundTrip(t *testing.T) {
+	useMockUi(t)
+	db := openTestDB(t)
+	// foreign_keys is a setting of the connection, the rebuilds must turn it
+	// back on.
+	db.SetMaxOpenConns(1)
+	create := []string{
+		"PRAGMA foreign_keys = ON",
+		"CREATE TABLE users (id integer PRIMARY KEY, name text NOT NULL, email text)",
+		"CREATE TABLE posts (id integer PRIMARY KEY, user_id integer NOT NULL REFERENCES users (id))",
+	}
+	for _, stmt := range append(create,
+		"INSERT INTO users (id, name, email) VALUES (1, 'app', 'app@example.com')",
+		"INSERT INTO posts (id, user_id) VALUES (1, 1)",
+	) {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	from, err := MigrationSet{}.SnapshotSchema(db, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := parseSchema(t,
+		"CREATE TABLE users (id integer PRIMARY KEY, email text NOT NULL DEFAULT '')",
+		"CREATE TABLE posts (id integer PRIMARY KEY, user_id integer NOT NULL REFERENCES users (id))",
+	)
+	up, err := SchemaChanges(from, to, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down, err := SchemaChanges(to, from, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := MemoryMigrationSource{Migrations: []*Migration{{
+		Id:                     "1_diff.sql",
+		Up:                     up,
+		Down:                   down,
+		DisableTransactionUp:   NeedsOwnTransaction(up),
+		DisableTransactionDown: NeedsOwnTransaction(down),
+		AllowDestructive:       true,
+	}}}
+
+	ms := MigrationSet{}
+	if _, err := ms.Exec(db, "sqlite3", source, Up); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := ms.SnapshotSchema(db, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmts, _ := SchemaChanges(snapshot, to, "sqlite3"); len(stmts) != 0 {
+		t.Errorf("database differs from the target after up: %q", stmts)
+	}
+
+	if _, err := ms.Exec(db, "sqlite3", source, Down); err != nil {
+		t.Fatal(err)
+	}
+	var name, email string
+	if err := db.QueryRow("SELECT name, email FROM users WHERE id = 1").Scan(&name, &email); err != nil {
+		t.Fatal(err)
+	}
+	if name != "" || email != "app@example.com" {
+		t.Errorf("after down: name %q, email %q", name, email)
+	}
+	var foreignKeys int
+	if err := db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		t.Fatal(err)
+	}
+	if foreignKeys != 1 {
+		t.Error("foreign keys left off")
+	}
+}
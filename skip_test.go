@@ -0,0 +1,100 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+)
+
+var skipMigrations = []*Migration{
+	{Id: "1_create_users.sql", Up: []string{"CREATE TABLE users (id INTEGER PRIMARY KEY)"}},
+	{Id: "2_create_posts.sql", Up: []string{"CREATE TABLE posts (id INTEGER PRIMARY KEY)"}},
+	{Id: "3_create_tags.sql", Up: []string{"CREATE TABLE tags (id INTEGER PRIMARY KEY)"}},
+	{Id: "4_create_likes.sql", Up: []string{"CREATE TABLE likes (id INTEGER PRIMARY KEY)"}},
+}
+
+func TestPlanSkip(t *testing.T) {
+	useMockUi(t)
+	db := openTestDB(t)
+	ms := MigrationSet{}
+	source := MemoryMigrationSource{Migrations: skipMigrations}
+	if _, err := ms.ExecMax(db, "sqlite3", source, Up, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		selection SkipSelection
+		want      string
+		err       string
+	}{
+		{"all pending", SkipSelection{}, "2_create_posts.sql 3_create_tags.sql 4_create_likes.sql", ""},
+		{"max", SkipSelection{Max: 2}, "2_create_posts.sql 3_create_tags.sql", ""},
+		{"to", SkipSelection{To: "3_create_tags.sql"}, "2_create_posts.sql 3_create_tags.sql", ""},
+		{"to and max", SkipSelection{To: "4_create_likes.sql", Max: 1}, "2_create_posts.sql", ""},
+		{"only", SkipSelection{Only: "3_create_tags.sql"}, "3_create_tags.sql", ""},
+		{"only applied", SkipSelection{Only: "1_create_users.sql"}, "", "Migration 1_create_users.sql is not pending"},
+		{"to unknown", SkipSelection{To: "5_create_votes.sql"}, "", "Migration 5_create_votes.sql is not pending"},
+		{"to and only", SkipSelection{To: "3_create_tags.sql", Only: "2_create_posts.sql"}, "", "Cannot skip both to and only a migration"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			planned, _, err := ms.PlanSkip(db, "sqlite3", source, test.selection)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("got %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, len(planned))
+			for i, pm := range planned {
+				ids[i] = pm.Id
+			}
+			if got := strings.Join(ids, " "); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSkip(t *testing.T) {
+	useMockUi(t)
+	db := openTestDB(t)
+	ms := MigrationSet{}
+	source := MemoryMigrationSource{Migrations: skipMigrations}
+
+	skipped, err := ms.Skip(db, "sqlite3", source, SkipSelection{Only: "2_create_posts.sql"})
+	if err != nil || skipped != 1 {
+		t.Fatalf("Skip = %d, %v", skipped, err)
+	}
+	if tableExists(t, db, "posts") {
+		t.Error("skip ran the migration")
+	}
+	records, err := ms.GetMigrationRecords(db, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Id != "2_create_posts.sql" {
+		t.Errorf("records = %v", records)
+	}
+}
+
+func TestSkipSelectedDryRun(t *testing.T) {
+	db := openTestDB(t)
+	m := New(Config{DB: db, Dialect: "sqlite3", MigrationSource: MemoryMigrationSource{Migrations: skipMigrations}})
+	mock := useMockUi(t)
+
+	if err := m.SkipSelected("sqlite3", db, SkipSelection{To: "2_create_posts.sql"}, true); err != nil {
+		t.Fatal(err)
+	}
+	want := "==> Would mark migration 1_create_users.sql as applied\n==> Would mark migration 2_create_posts.sql as applied\n"
+	if got := mock.OutputWriter.String(); got != want {
+		t.Errorf("output %q, want %q", got, want)
+	}
+	// Nothing is recorded, the tracking table is not even created.
+	if tableExists(t, db, defaultTableName) {
+		t.Error("dry run created the tracking table")
+	}
+}
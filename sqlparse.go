@@ -20,6 +20,22 @@ type ParsedMigration struct {
 
 	DisableTransactionUp   bool
 	DisableTransactionDown bool
+
+	// DependsOn lists the ids of the migrations that must be applied
+	// before this one, as declared by '-- +migrate DependsOn' headers.
+	DependsOn []string
+
+	// AllowDestructive is set by a '-- +migrate Destructive' header, which
+	// acknowledges the destructive statements of the migration.
+	AllowDestructive bool
+	DestructiveUp    []DestructiveStatement
+	DestructiveDown  []DestructiveStatement
+
+	// NonTransactionalUp and NonTransactionalDown are the statements of
+	// the directions not marked notransaction that some dialect can not
+	// run inside a transaction, see NonTransactionalStatements.
+	NonTransactionalUp   []string
+	NonTransactionalDown []string
 }
 
 var (
@@ -160,6 +176,17 @@ func Parse(r io.ReadSeeker) (*ParsedMigration, error) {
 				}
 				break
 
+			case "DependsOn":
+				if len(cmd.Options) == 0 {
+					return nil, errors.New(`ERROR: '-- +migrate DependsOn' requires at least one migration id`)
+				}
+				p.DependsOn = append(p.DependsOn, cmd.Options...)
+				break
+
+			case "Destructive":
+				p.AllowDestructive = true
+				break
+
 			case "StatementBegin":
 				if currentDirection != directionNone {
 					ignoreSemicolons = true
@@ -227,5 +254,14 @@ func Parse(r io.ReadSeeker) (*ParsedMigration, error) {
 		return nil, errNoTerminator()
 	}
 
+	p.DestructiveUp = ClassifyStatements(p.UpStatements)
+	p.DestructiveDown = ClassifyStatements(p.DownStatements)
+	if !p.DisableTransactionUp {
+		p.NonTransactionalUp = NonTransactionalStatements("", p.UpStatements)
+	}
+	if !p.DisableTransactionDown {
+		p.NonTransactionalDown = NonTransactionalStatements("", p.DownStatements)
+	}
+
 	return p, nil
 }
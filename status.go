@@ -0,0 +1,232 @@
+package migration
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+)
+
+// StatusRow is the state of a single migration.
+type StatusRow struct {
+	Id        string     `json:"id" yaml:"id"`
+	Namespace string     `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Applied   bool       `json:"applied" yaml:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty" yaml:"applied_at,omitempty"`
+	// OutOfOrder is set on pending migrations planned before an applied one,
+	// and on migrations applied after one planned later. Migrations are
+	// planned in Id order, or in dependency order when some have DependsOn.
+	OutOfOrder bool `json:"out_of_order" yaml:"out_of_order"`
+	// Checksum compares the checksum recorded when the migration was
+	// applied with the source, empty for pending migrations.
+	Checksum ChecksumState `json:"checksum,omitempty" yaml:"checksum,omitempty"`
+	// DurationMs is the recorded duration of an applied migration, in
+	// milliseconds.
+	DurationMs *int64 `json:"duration_ms,omitempty" yaml:"duration_ms,omitempty"`
+}
+
+// ChecksumState tells whether an applied migration was changed since.
+type ChecksumState string
+
+const (
+	ChecksumOK      ChecksumState = "ok"
+	ChecksumChanged ChecksumState = "changed"
+	// ChecksumMissing no checksum was recorded, as for migrations applied
+	// before checksums were.
+	ChecksumMissing ChecksumState = "missing"
+)
+
+// StatusReport is the state of every migration of a source, in order.
+type StatusReport struct {
+	Migrations []*StatusRow `json:"migrations" yaml:"migrations"`
+	// Unknown lists the ids of applied migrations missing from the source.
+	Unknown []string `json:"unknown,omitempty" yaml:"unknown,omitempty"`
+	// Changed lists the ids of applied migrations whose checksum differs
+	// from the source.
+	Changed []string `json:"changed,omitempty" yaml:"changed,omitempty"`
+}
+
+// StatusFilter selects the rows of a StatusReport. The zero value keeps
+// every row.
+type StatusFilter struct {
+	Pending bool
+	Applied bool
+	// Since keeps the migrations applied at or after this time.
+	Since time.Time
+}
+
+// GetStatus compares the migrations of the source with the applied ones.
+func GetStatus(db *sql.DB, dialect string, m MigrationSource) (*StatusReport, error) {
+	return migSet.GetStatus(db, dialect, m)
+}
+
+func (ms MigrationSet) GetStatus(db *sql.DB, dialect string, m MigrationSource) (*StatusReport, error) {
+	migrations, err := m.FindMigrations()
+	if err != nil {
+		return nil, err
+	}
+	// Reading the status creates nothing, not even the tracking table.
+	records, err := ms.appliedRecords(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return newStatusReport(migrations, records)
+}
+
+func newStatusReport(migrations []*Migration, records []*MigrationRecord) (*StatusReport, error) {
+	report := &StatusReport{Migrations: []*StatusRow{}}
+	rows := make(map[string]*StatusRow, len(migrations))
+	sources := make(map[string]*Migration, len(migrations))
+	for _, m := range migrations {
+		row := &StatusRow{Id: m.Id, Namespace: m.Namespace}
+		rows[m.Id] = row
+		sources[m.Id] = m
+		report.Migrations = append(report.Migrations, row)
+	}
+	for _, r := range records {
+		row, ok := rows[r.Id]
+		if !ok {
+			report.Unknown = append(report.Unknown, r.Id)
+			continue
+		}
+		appliedAt := r.AppliedAt
+		row.Applied = true
+		row.AppliedAt = &appliedAt
+		switch {
+		case r.Checksum == "":
+			row.Checksum = ChecksumMissing
+		case r.Checksum == sources[r.Id].Checksum():
+			row.Checksum = ChecksumOK
+		default:
+			row.Checksum = ChecksumChanged
+			report.Changed = append(report.Changed, r.Id)
+		}
+		if r.Duration != nil {
+			ms := r.Duration.Milliseconds()
+			row.DurationMs = &ms
+		}
+	}
+
+	// Migrations with DependsOn headers are applied in dependency order, not
+	// in Id order.
+	order := report.Migrations
+	if hasDependencies(migrations) {
+		sorted, err := SortByDependencies(migrations)
+		if err != nil {
+			return nil, err
+		}
+		order = make([]*StatusRow, len(sorted))
+		for i, m := range sorted {
+			order[i] = rows[m.Id]
+		}
+	}
+
+	// Walk from the last migration planned, remembering whether a later one
+	// is applied and the earliest time one was.
+	laterApplied := false
+	var earliest time.Time
+	for i := len(order) - 1; i >= 0; i-- {
+		row := order[i]
+		if !row.Applied {
+			row.OutOfOrder = laterApplied
+			continue
+		}
+		row.OutOfOrder = laterApplied && row.AppliedAt.After(earliest)
+		if !laterApplied || row.AppliedAt.Before(earliest) {
+			earliest = *row.AppliedAt
+		}
+		laterApplied = true
+	}
+	return report, nil
+}
+
+// Filter returns the report restricted to the rows matching filter.
+func (r *StatusReport) Filter(filter StatusFilter) *StatusReport {
+	filtered := &StatusReport{Migrations: []*StatusRow{}, Unknown: r.Unknown, Changed: r.Changed}
+	for _, row := range r.Migrations {
+		if filter.Pending != filter.Applied && row.Applied != filter.Applied {
+			continue
+		}
+		if !filter.Since.IsZero() && (!row.Applied || row.AppliedAt.Before(filter.Since)) {
+			continue
+		}
+		filtered.Migrations = append(filtered.Migrations, row)
+	}
+	return filtered
+}
+
+// ParseSince parses the -since value of the status command, either a date
+// or an RFC 3339 time.
+func ParseSince(value string) (time.Time, error) {
+	for _, layout := range []string{time.DateOnly, time.RFC3339, time.DateTime} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Invalid date: %s, expected YYYY-MM-DD or RFC 3339", value)
+}
+
+func (r *StatusReport) cells() [][]string {
+	var cells [][]string
+	for _, row := range r.Migrations {
+		applied := "no"
+		if row.Applied {
+			applied = row.AppliedAt.String()
+		}
+		outOfOrder := ""
+		if row.OutOfOrder {
+			outOfOrder = "yes"
+		}
+		duration := ""
+		if row.DurationMs != nil {
+			duration = (time.Duration(*row.DurationMs) * time.Millisecond).String()
+		}
+		cells = append(cells, []string{row.Id, row.Namespace, applied, string(row.Checksum), duration, outOfOrder})
+	}
+	return cells
+}
+
+var statusHeader = []string{"Migration", "Namespace", "Applied", "Checksum", "Duration", "Out of order"}
+
+// Write renders the report as a table, JSON, YAML or a markdown table.
+func (r *StatusReport) Write(w io.Writer, format string) error {
+	switch format {
+	case "", "table":
+		table := tablewriter.NewWriter(w)
+		table.Header(statusHeader)
+		for _, row := range r.cells() {
+			table.Append(row)
+		}
+		return table.Render()
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "markdown":
+		var b strings.Builder
+		b.WriteString("| " + strings.Join(statusHeader, " | ") + " |\n")
+		b.WriteString(strings.Repeat("| --- ", len(statusHeader)) + "|\n")
+		for _, row := range r.cells() {
+			for i, cell := range row {
+				row[i] = strings.ReplaceAll(cell, "|", `\|`)
+			}
+			b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+	default:
+		return fmt.Errorf("Unknown format: %s", format)
+	}
+}
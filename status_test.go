@@ -0,0 +1,237 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var statusTime = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// statusRecords returns records of the ids applied a minute apart, in the
+// order given.
+func statusRecords(migrations []*Migration, ids ...string) []*MigrationRecord {
+	sources := make(map[string]*Migration)
+	for _, m := range migrations {
+		sources[m.Id] = m
+	}
+	var records []*MigrationRecord
+	for i, id := range ids {
+		record := &MigrationRecord{Id: id, AppliedAt: statusTime.Add(time.Duration(i) * time.Minute)}
+		if m, ok := sources[id]; ok {
+			record.Checksum = m.Checksum()
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func statusSummary(report *StatusReport) string {
+	var rows []string
+	for _, row := range report.Migrations {
+		state := "pending"
+		if row.Applied {
+			state = "applied"
+		}
+		if row.OutOfOrder {
+			state += ",out_of_order"
+		}
+		rows = append(rows, row.Id+":"+state)
+	}
+	return strings.Join(rows, " ")
+}
+
+func TestStatusOutOfOrder(t *testing.T) {
+	linear := []*Migration{{Id: "1_a.sql"}, {Id: "2_b.sql"}, {Id: "3_c.sql"}}
+	dependent := []*Migration{{Id: "1_a.sql"}, {Id: "2_b.sql", DependsOn: []string{"3_c"}}, {Id: "3_c.sql"}}
+
+	tests := []struct {
+		name       string
+		migrations []*Migration
+		applied    []string
+		want       string
+	}{
+		{"in order", linear, []string{"1_a.sql", "2_b.sql"}, "1_a.sql:applied 2_b.sql:applied 3_c.sql:pending"},
+		{"pending gap", linear, []string{"1_a.sql", "3_c.sql"}, "1_a.sql:applied 2_b.sql:pending,out_of_order 3_c.sql:applied"},
+		{"applied late", linear, []string{"1_a.sql", "3_c.sql", "2_b.sql"}, "1_a.sql:applied 2_b.sql:applied,out_of_order 3_c.sql:applied"},
+		{"dependency order", dependent, []string{"1_a.sql", "3_c.sql"}, "1_a.sql:applied 2_b.sql:pending 3_c.sql:applied"},
+		{"dependency applied late", dependent, []string{"1_a.sql", "3_c.sql", "2_b.sql"}, "1_a.sql:applied 2_b.sql:applied 3_c.sql:applied"},
+		{"dependency gap", dependent, []string{"3_c.sql", "2_b.sql"}, "1_a.sql:pending,out_of_order 2_b.sql:applied 3_c.sql:applied"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report, err := newStatusReport(test.migrations, statusRecords(test.migrations, test.applied...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := statusSummary(report); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	cyclic := []*Migration{{Id: "1_a.sql", DependsOn: []string{"2_b"}}, {Id: "2_b.sql", DependsOn: []string{"1_a"}}}
+	if _, err := newStatusReport(cyclic, nil); err == nil {
+		t.Error("no error for a dependency cycle")
+	}
+}
+
+func TestStatusChecksums(t *testing.T) {
+	migrations := []*Migration{
+		{Id: "1_a.sql", Up: []string{"CREATE TABLE a (id int)"}},
+		{Id: "2_b.sql", Up: []string{"CREATE TABLE b (id int)"}},
+		{Id: "3_c.sql", Up: []string{"CREATE TABLE c (id int)"}},
+	}
+	records := statusRecords(migrations, "1_a.sql", "2_b.sql", "3_c.sql", "0_gone.sql")
+	records[1].Checksum = "edited"
+	records[2].Checksum = ""
+	duration := 1500 * time.Millisecond
+	records[0].Duration = &duration
+
+	report, err := newStatusReport(migrations, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var states []string
+	for _, row := range report.Migrations {
+		states = append(states, string(row.Checksum))
+	}
+	if got := strings.Join(states, " "); got != "ok changed missing" {
+		t.Errorf("checksums %s", got)
+	}
+	if strings.Join(report.Changed, " ") != "2_b.sql" || strings.Join(report.Unknown, " ") != "0_gone.sql" {
+		t.Errorf("changed %v, unknown %v", report.Changed, report.Unknown)
+	}
+	if ms := report.Migrations[0].DurationMs; ms == nil || *ms != 1500 {
+		t.Errorf("duration %v", ms)
+	}
+}
+
+func TestStatusFilter(t *testing.T) {
+	migrations := []*Migration{{Id: "1_a.sql"}, {Id: "2_b.sql"}, {Id: "3_c.sql"}}
+	report, err := newStatusReport(migrations, statusRecords(migrations, "1_a.sql", "2_b.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter StatusFilter
+		want   string
+	}{
+		{"all", StatusFilter{}, "1_a.sql 2_b.sql 3_c.sql"},
+		{"pending and applied", StatusFilter{Pending: true, Applied: true}, "1_a.sql 2_b.sql 3_c.sql"},
+		{"pending", StatusFilter{Pending: true}, "3_c.sql"},
+		{"applied", StatusFilter{Applied: true}, "1_a.sql 2_b.sql"},
+		{"since", StatusFilter{Since: statusTime.Add(30 * time.Second)}, "2_b.sql"},
+		{"since at", StatusFilter{Since: statusTime}, "1_a.sql 2_b.sql"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var ids []string
+			for _, row := range report.Filter(test.filter).Migrations {
+				ids = append(ids, row.Id)
+			}
+			if got := strings.Join(ids, " "); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStatusWrite(t *testing.T) {
+	migrations := []*Migration{{Id: "1_a.sql"}, {Id: "2_b|c.sql"}}
+	report, err := newStatusReport(migrations, statusRecords(migrations, "1_a.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"json", `{
+  "migrations": [
+    {
+      "id": "1_a.sql",
+      "applied": true,
+      "applied_at": "2026-01-02T03:04:05Z",
+      "out_of_order": false,
+      "checksum": "ok"
+    },
+    {
+      "id": "2_b|c.sql",
+      "applied": false,
+      "out_of_order": false
+    }
+  ]
+}
+`},
+		{"yaml", `migrations:
+  - id: 1_a.sql
+    applied: true
+    applied_at: 2026-01-02T03:04:05Z
+    out_of_order: false
+    checksum: ok
+  - id: 2_b|c.sql
+    applied: false
+    out_of_order: false
+`},
+		{"markdown", `| Migration | Namespace | Applied | Checksum | Duration | Out of order |
+| --- | --- | --- | --- | --- | --- |
+| 1_a.sql |  | 2026-01-02 03:04:05 +0000 UTC | ok |  |  |
+| 2_b\|c.sql |  | no |  |  |  |
+`},
+	}
+	for _, test := range tests {
+		var b strings.Builder
+		if err := report.Write(&b, test.format); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != test.want {
+			t.Errorf("%s:\n%s\nwant:\n%s", test.format, b.String(), test.want)
+		}
+	}
+
+	var b strings.Builder
+	if err := report.Write(&b, "table"); err != nil || !strings.Contains(b.String(), "1_a.sql") {
+		t.Errorf("table: %v\n%s", err, b.String())
+	}
+	if err := report.Write(&b, "xml"); err == nil || err.Error() != "Unknown format: xml" {
+		t.Errorf("xml: %v", err)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+		err   bool
+	}{
+		{"2026-01-02", time.Date(2026, 1, 2, 0, 0, 0, 0, time.Local), false},
+		{"2026-01-02T03:04:05Z", statusTime, false},
+		{"2026-01-02 03:04:05", time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local), false},
+		{"yesterday", time.Time{}, true},
+	}
+	for _, test := range tests {
+		got, err := ParseSince(test.value)
+		if (err != nil) != test.err || !got.Equal(test.want) {
+			t.Errorf("ParseSince(%q) = %v, %v", test.value, got, err)
+		}
+	}
+}
+
+func TestGetStatusFreshDatabase(t *testing.T) {
+	db := openTestDB(t)
+	source := MemoryMigrationSource{Migrations: []*Migration{{Id: "1_a.sql", Up: []string{"CREATE TABLE a (id int)"}}}}
+	report, err := MigrationSet{}.GetStatus(db, "sqlite3", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := statusSummary(report); got != "1_a.sql:pending" {
+		t.Errorf("got %s", got)
+	}
+	if tableExists(t, db, "gorp_migrations") {
+		t.Error("status created the tracking table")
+	}
+}
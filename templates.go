@@ -0,0 +1,147 @@
+package migration
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const templateExt = ".sql.tmpl"
+
+// TemplateData is passed to the templates of the new command.
+type TemplateData struct {
+	// Name of the migration, without directory nor timestamp.
+	Name string
+	// Table guessed from the name, such as users for create_users_table,
+	// unless given with -var table=...
+	Table     string
+	Timestamp string
+	Dialect   string
+	// Author is the author var, else $MIGRATION_AUTHOR, else the current
+	// user.
+	Author string
+	// Vars holds the -var key=value flags.
+	Vars map[string]string
+}
+
+// templateVars collects repeated -var key=value flags.
+type templateVars map[string]string
+
+func (v templateVars) String() string {
+	pairs := make([]string, 0, len(v))
+	for key, value := range v {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v templateVars) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("Invalid variable %q, expected key=value", s)
+	}
+	v[key] = value
+	return nil
+}
+
+// tableFromName guesses the table a migration is about from its name:
+// users for create_users_table or add_email_in_users_table.
+func tableFromName(name string) string {
+	parts := strings.Split(name, "_")
+	if len(parts) > 1 && parts[len(parts)-1] == "table" {
+		parts = parts[:len(parts)-1]
+	}
+	for i := len(parts) - 1; i > 0; i-- {
+		switch parts[i] {
+		case "in", "from", "to", "on":
+			return strings.Join(parts[i+1:], "_")
+		}
+	}
+	if len(parts) > 1 {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, "_")
+}
+
+// templateAuthor returns the default author of templated migrations.
+func templateAuthor() string {
+	if author := os.Getenv("MIGRATION_AUTHOR"); author != "" {
+		return author
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+// Templates lists the names of the templates found in TemplatesDir.
+func (m *Migrate) Templates() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(m.TemplatesDir, "*"+templateExt))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(file), templateExt))
+	}
+	return names, nil
+}
+
+// RenderTemplate renders the template named name, read from
+// TemplatesDir/name.sql.tmpl, for the migration called migrationName.
+func (m *Migrate) RenderTemplate(name, migrationName string, vars map[string]string) (string, error) {
+	file := filepath.Join(m.TemplatesDir, name+templateExt)
+	content, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			available, _ := m.Templates()
+			return "", fmt.Errorf("Unknown template %s, available in %s: %s", name, m.TemplatesDir, strings.Join(available, ", "))
+		}
+		return "", err
+	}
+	tmpl, err := template.New(name).Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("Invalid template %s: %s", name, err)
+	}
+
+	base := strings.TrimSuffix(path.Base(migrationName), ".sql")
+	data := TemplateData{
+		Name:      base,
+		Table:     firstNonEmpty(vars["table"], tableFromName(base)),
+		Timestamp: time.Now().Format("20060102150405"),
+		Dialect:   m.Dialect,
+		Author:    firstNonEmpty(vars["author"], templateAuthor()),
+		Vars:      vars,
+	}
+	if data.Vars == nil {
+		data.Vars = map[string]string{}
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("Cannot render template %s: %s", name, err)
+	}
+	if _, err := ParseMigration(base, bytes.NewReader(b.Bytes())); err != nil {
+		return "", fmt.Errorf("Template %s does not render a valid migration: %s", name, err)
+	}
+	return b.String(), nil
+}
+
+// CreateFromTemplate creates a new migration from a template of
+// TemplatesDir, see RenderTemplate.
+func (m *Migrate) CreateFromTemplate(name, templateName string, vars map[string]string) error {
+	name = strings.ToLower(name)
+	content, err := m.RenderTemplate(templateName, name, vars)
+	if err != nil {
+		return err
+	}
+	return m.writeMigration(name, content)
+}
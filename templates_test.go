@@ -0,0 +1,114 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTableFromName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"create_users_table", "users"},
+		{"create_users", "users"},
+		{"create_user_roles_table", "user_roles"},
+		{"add_email_in_users_table", "users"},
+		{"remove_email_from_users", "users"},
+		{"add_index_on_posts", "posts"},
+		{"seed", "seed"},
+	}
+	for _, test := range tests {
+		if got := tableFromName(test.name); got != test.want {
+			t.Errorf("tableFromName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestTemplateVars(t *testing.T) {
+	vars := templateVars{}
+	for _, s := range []string{"table=accounts", "author=ops", "empty="} {
+		if err := vars.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := vars.String(); got != "author=ops,empty=,table=accounts" {
+		t.Errorf("String() = %q", got)
+	}
+	for _, s := range []string{"table", "=accounts"} {
+		if err := vars.Set(s); err == nil {
+			t.Errorf("Set(%q) succeeded", s)
+		}
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	dir := t.TempDir()
+	templates := map[string]string{
+		"table": `-- +migrate Up
+-- {{.Name}} by {{.Author}} for {{.Dialect}}
+CREATE TABLE {{.Table}} (id integer PRIMARY KEY{{if .Vars.tenant}}, tenant_id integer NOT NULL{{end}});
+
+-- +migrate Down
+DROP TABLE {{.Table}};
+`,
+		"broken":  "-- +migrate Up\n{{.Missing",
+		"invalid": "CREATE TABLE {{.Table}} (id integer);\n",
+		"unknown": "-- +migrate Up\n{{.Vars.table.Name}}\n",
+	}
+	for name, content := range templates {
+		if err := os.WriteFile(filepath.Join(dir, name+templateExt), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := &Migrate{TemplatesDir: dir, Dialect: "postgresql"}
+
+	names, err := m.Templates()
+	if err != nil || strings.Join(names, " ") != "broken invalid table unknown" {
+		t.Errorf("Templates() = %v, %v", names, err)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		vars     map[string]string
+		want     string
+		err      string
+	}{
+		{
+			name:     "guessed table",
+			template: "table",
+			vars:     map[string]string{"author": "ops"},
+			want:     "-- +migrate Up\n-- create_users_table by ops for postgresql\nCREATE TABLE users (id integer PRIMARY KEY);\n\n-- +migrate Down\nDROP TABLE users;\n",
+		},
+		{
+			name:     "vars",
+			template: "table",
+			vars:     map[string]string{"author": "ops", "table": "accounts", "tenant": "yes"},
+			want:     "-- +migrate Up\n-- create_users_table by ops for postgresql\nCREATE TABLE accounts (id integer PRIMARY KEY, tenant_id integer NOT NULL);\n\n-- +migrate Down\nDROP TABLE accounts;\n",
+		},
+		{name: "missing template", template: "view", err: "Unknown template view, available in " + dir + ": broken, invalid, table, unknown"},
+		{name: "parse error", template: "broken", err: "Invalid template broken:"},
+		{name: "execution error", template: "unknown", vars: map[string]string{"table": "users"}, err: "Cannot render template unknown:"},
+		{name: "invalid migration", template: "invalid", err: "Template invalid does not render a valid migration:"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := m.RenderTemplate(test.template, "create_users_table", test.vars)
+			if test.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+					t.Fatalf("got %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package migration
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TenantFailurePolicy decides what a TenantRunner does once a tenant fails.
+type TenantFailurePolicy int
+
+const (
+	// StopOnFirstFailure stops scheduling tenants after the first failure.
+	// Tenants that are already running are allowed to finish.
+	StopOnFirstFailure TenantFailurePolicy = iota
+	// ContinueOnFailure keeps migrating the remaining tenants.
+	ContinueOnFailure
+)
+
+// TenantRunner applies the same migrations to one postgresql schema per
+// tenant. Every tenant gets its own MigrationSet, with the tracking table and
+// search_path pointing at the tenant schema.
+type TenantRunner struct {
+	DB      *sql.DB
+	Dialect string
+	Source  MigrationSource
+	// TableName name of the tracking table created inside every tenant schema.
+	TableName string
+	// Tenants static list of tenant schemas.
+	Tenants []string
+	// TenantQuery query returning one schema name per row. Its result is
+	// appended to Tenants.
+	TenantQuery string
+	// Parallelism max number of tenants migrated at the same time.
+	// Defaults to 1.
+	Parallelism int
+	Policy      TenantFailurePolicy
+	// AllowDestructive see MigrationSet.AllowDestructive.
+	AllowDestructive bool
+	// NonTransactional and Locking see MigrationSet.
+	NonTransactional NonTransactionalPolicy
+	Locking          bool
+	// SchemaDumpDir, when set, receives the schema of every tenant in a
+	// directory named after the tenant.
+	SchemaDumpDir string
+}
+
+// TenantResult is the outcome of migrating a single tenant.
+type TenantResult struct {
+	Tenant   string
+	Applied  int
+	Duration time.Duration
+	// Skipped is set when the tenant was never started because an earlier
+	// tenant failed under StopOnFirstFailure.
+	Skipped bool
+	Err     error
+}
+
+// TenantStatus summarises the migration state of a single tenant.
+type TenantStatus struct {
+	Tenant  string
+	Applied int
+	Pending int
+	Unknown int
+	Latest  string
+	Err     error
+}
+
+// ResolveTenants returns the static tenant list followed by the tenants
+// returned by TenantQuery, without duplicates.
+func (r TenantRunner) ResolveTenants() ([]string, error) {
+	seen := make(map[string]bool)
+	var tenants []string
+	add := func(tenant string) {
+		if tenant != "" && !seen[tenant] {
+			seen[tenant] = true
+			tenants = append(tenants, tenant)
+		}
+	}
+	for _, tenant := range r.Tenants {
+		add(tenant)
+	}
+
+	if r.TenantQuery != "" {
+		rows, err := r.DB.Query(r.TenantQuery)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot list tenants: %s", err)
+		}
+		defer func() { _ = rows.Close() }()
+		for rows.Next() {
+			var tenant string
+			if err := rows.Scan(&tenant); err != nil {
+				return nil, fmt.Errorf("Cannot list tenants: %s", err)
+			}
+			add(tenant)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("Cannot list tenants: %s", err)
+		}
+	}
+
+	if len(tenants) == 0 {
+		return nil, errors.New("No tenants configured")
+	}
+	return tenants, nil
+}
+
+// MigrationSet returns the MigrationSet used for the given tenant.
+func (r TenantRunner) MigrationSet(tenant string) MigrationSet {
+	ms := MigrationSet{
+		TableName:  r.TableName,
+		SchemaName: tenant,
+		SearchPath: PostgresDialect{}.QuoteIdentifier(tenant) + ", public",
+
+		AllowDestructive: r.AllowDestructive,
+		NonTransactional: r.NonTransactional,
+		Locking:          r.Locking,
+	}
+	if r.SchemaDumpDir != "" {
+		ms.SchemaDumpDir = filepath.Join(r.SchemaDumpDir, tenant)
+	}
+	return ms
+}
+
+// ExecMax applies at most max migrations to every tenant. Pass 0 for no limit.
+//
+// The returned results follow the order of ResolveTenants. The error is only
+// set when the tenants could not be resolved; per-tenant failures are
+// reported in TenantResult.Err.
+func (r TenantRunner) ExecMax(dir MigrationDirection, max int) ([]*TenantResult, error) {
+	if CanonicalDialect(r.Dialect) != "postgresql" {
+		return nil, fmt.Errorf("Tenant schemas are not supported by dialect: %s", r.Dialect)
+	}
+	tenants, err := r.ResolveTenants()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*TenantResult, len(tenants))
+	for i, tenant := range tenants {
+		results[i] = &TenantResult{Tenant: tenant, Skipped: true}
+	}
+
+	var (
+		mu     sync.Mutex
+		failed bool
+		wg     sync.WaitGroup
+	)
+	jobs := make(chan int)
+	for w := 0; w < r.parallelism(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				result := results[i]
+				start := time.Now()
+				result.Skipped = false
+				result.Applied, result.Err = r.MigrationSet(result.Tenant).ExecMax(r.DB, r.Dialect, r.Source, dir, max)
+				result.Duration = time.Since(start)
+				if result.Err != nil {
+					mu.Lock()
+					failed = true
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range tenants {
+		mu.Lock()
+		stop := failed && r.Policy == StopOnFirstFailure
+		mu.Unlock()
+		if stop {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, nil
+}
+
+// Status returns the migration state of every tenant, without changing
+// anything.
+func (r TenantRunner) Status() ([]*TenantStatus, error) {
+	if CanonicalDialect(r.Dialect) != "postgresql" {
+		return nil, fmt.Errorf("Tenant schemas are not supported by dialect: %s", r.Dialect)
+	}
+	tenants, err := r.ResolveTenants()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := r.Source.FindMigrations()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Id] = true
+	}
+
+	statuses := make([]*TenantStatus, len(tenants))
+	for i, tenant := range tenants {
+		status := &TenantStatus{Tenant: tenant}
+		statuses[i] = status
+
+		// Tenants whose schema or tracking table is missing have nothing
+		// applied, neither is created.
+		records, err := r.MigrationSet(tenant).appliedRecords(r.DB, r.Dialect)
+		if err != nil {
+			status.Err = err
+			continue
+		}
+		for _, record := range records {
+			if known[record.Id] {
+				status.Applied++
+			} else {
+				status.Unknown++
+			}
+			status.Latest = record.Id
+		}
+		status.Pending = len(migrations) - status.Applied
+	}
+	return statuses, nil
+}
+
+func (r TenantRunner) parallelism() int {
+	if r.Parallelism < 1 {
+		return 1
+	}
+	return r.Parallelism
+}
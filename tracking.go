@@ -0,0 +1,232 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SqlExecutor runs statements, either directly on a *sql.DB or inside a
+// *sql.Tx.
+type SqlExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// TrackingTable is the table recording the applied migrations of a
+// MigrationSet.
+type TrackingTable struct {
+	DB         *sql.DB
+	Dialect    Dialect
+	SchemaName string
+	TableName  string
+
+	// extended is set once the table is known to have the trackingColumns.
+	extended bool
+}
+
+// trackingColumns record the checksum and duration of applied migrations.
+// They are added to the tables created by CreateTrackingTable, including
+// the ones created before they existed.
+var trackingColumns = []struct{ name, kind string }{
+	{"checksum", "varchar(64)"},
+	{"duration_ms", "bigint"},
+}
+
+// Begin starts a transaction on the database of the table.
+func (t *TrackingTable) Begin() (*sql.Tx, error) {
+	return t.DB.Begin()
+}
+
+func (t *TrackingTable) quoted() string {
+	return quotedTable(t.Dialect, t.SchemaName, t.TableName)
+}
+
+// create creates the table unless it exists, and adds the trackingColumns
+// it lacks.
+func (t *TrackingTable) create() error {
+	for _, stmt := range t.Dialect.CreateTrackingTable(t.SchemaName, t.TableName) {
+		if _, err := t.DB.Exec(stmt); err != nil && t.Dialect.ClassifyError(err) != ErrorAlreadyExists {
+			return err
+		}
+	}
+
+	columns, err := t.columns()
+	if err != nil {
+		return err
+	}
+	for _, c := range trackingColumns {
+		if columns[c.name] {
+			continue
+		}
+		if _, err := t.DB.Exec(addColumnSQL(t.Dialect, t.quoted(), t.Dialect.QuoteIdentifier(c.name), c.kind)); err != nil {
+			// Another run may have added it meanwhile.
+			if columns, _ := t.columns(); !columns[c.name] {
+				return err
+			}
+		}
+	}
+	t.extended = true
+	return nil
+}
+
+// columns returns the lower cased names of the columns of the table,
+// without changing anything.
+func (t *TrackingTable) columns() (map[string]bool, error) {
+	rows, err := t.DB.Query(fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", t.quoted()))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]bool, len(names))
+	for _, name := range names {
+		columns[strings.ToLower(name)] = true
+	}
+	return columns, rows.Err()
+}
+
+// addColumnSQL returns the statement adding a column of a generic type to
+// table.
+func addColumnSQL(d Dialect, table, column, kind string) string {
+	if _, ok := d.(OracleDialect); ok {
+		kind = strings.NewReplacer("varchar", "VARCHAR2", "bigint", "NUMBER(19)").Replace(kind)
+		return fmt.Sprintf("ALTER TABLE %s ADD (%s %s)", table, column, kind)
+	}
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, kind)
+}
+
+// Insert records a migration as applied.
+func (t *TrackingTable) Insert(executor SqlExecutor, record *MigrationRecord) error {
+	if !t.extended {
+		query := fmt.Sprintf("INSERT INTO %s (%s, %s) VALUES (%s, %s)", t.quoted(),
+			t.Dialect.QuoteIdentifier("id"), t.Dialect.QuoteIdentifier("applied_at"),
+			t.Dialect.Placeholder(1), t.Dialect.Placeholder(2))
+		_, err := executor.Exec(query, record.Id, record.AppliedAt)
+		return err
+	}
+
+	checksum := sql.NullString{String: record.Checksum, Valid: record.Checksum != ""}
+	var duration sql.NullInt64
+	if record.Duration != nil {
+		duration = sql.NullInt64{Int64: record.Duration.Milliseconds(), Valid: true}
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s) VALUES (%s, %s, %s, %s)", t.quoted(),
+		t.Dialect.QuoteIdentifier("id"), t.Dialect.QuoteIdentifier("applied_at"),
+		t.Dialect.QuoteIdentifier("checksum"), t.Dialect.QuoteIdentifier("duration_ms"),
+		t.Dialect.Placeholder(1), t.Dialect.Placeholder(2), t.Dialect.Placeholder(3), t.Dialect.Placeholder(4))
+	_, err := executor.Exec(query, record.Id, record.AppliedAt, checksum, duration)
+	return err
+}
+
+// Delete forgets an applied migration.
+func (t *TrackingTable) Delete(executor SqlExecutor, id string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = %s", t.quoted(),
+		t.Dialect.QuoteIdentifier("id"), t.Dialect.Placeholder(1))
+	_, err := executor.Exec(query, id)
+	return err
+}
+
+// Records returns the applied migrations, ordered by id.
+func (t *TrackingTable) Records() ([]*MigrationRecord, error) {
+	columns, err := t.columns()
+	if err != nil {
+		return nil, err
+	}
+	extended := columns["checksum"] && columns["duration_ms"]
+
+	selected := []string{t.Dialect.QuoteIdentifier("id"), t.Dialect.QuoteIdentifier("applied_at")}
+	if extended {
+		selected = append(selected, t.Dialect.QuoteIdentifier("checksum"), t.Dialect.QuoteIdentifier("duration_ms"))
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY %s ASC",
+		strings.Join(selected, ", "), t.quoted(), t.Dialect.QuoteIdentifier("id"))
+	rows, err := t.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var records []*MigrationRecord
+	for rows.Next() {
+		var record MigrationRecord
+		var appliedAt sql.NullTime
+		var checksum sql.NullString
+		var duration sql.NullInt64
+		dest := []interface{}{&record.Id, &appliedAt}
+		if extended {
+			dest = append(dest, &checksum, &duration)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		record.AppliedAt = appliedAt.Time
+		record.Checksum = checksum.String
+		if duration.Valid {
+			d := time.Duration(duration.Int64) * time.Millisecond
+			record.Duration = &d
+		}
+		records = append(records, &record)
+	}
+	return records, rows.Err()
+}
+
+// trackingTable returns the tracking table of the set, creating it if
+// needed.
+func (ms MigrationSet) trackingTable(db *sql.DB, dialect string) (*TrackingTable, error) {
+	table, err := ms.existingTrackingTable(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	if err := table.create(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// appliedRecords returns the applied migrations without creating anything,
+// none when the tracking table or its schema does not exist.
+func (ms MigrationSet) appliedRecords(db *sql.DB, dialect string) ([]*MigrationRecord, error) {
+	table, err := ms.existingTrackingTable(db, CanonicalDialect(dialect))
+	if err != nil {
+		return nil, err
+	}
+	records, err := table.Records()
+	if err != nil && table.Dialect.ClassifyError(err) == ErrorUndefinedObject {
+		return nil, nil
+	}
+	return records, err
+}
+
+// existingTrackingTable returns the tracking table of the set without
+// creating it.
+func (ms MigrationSet) existingTrackingTable(db *sql.DB, dialect string) (*TrackingTable, error) {
+	if db == nil {
+		return nil, ErrNoDatabase
+	}
+	d, err := GetDialect(dialect)
+	if err != nil {
+		return nil, err
+	}
+	if validator, ok := d.(ConnectionValidator); ok {
+		if err := validator.ValidateConnection(db); err != nil {
+			return nil, err
+		}
+	}
+
+	return &TrackingTable{
+		DB:         db,
+		Dialect:    d,
+		SchemaName: ms.SchemaName,
+		TableName:  ms.getTableName(),
+	}, nil
+}
+
+// newRecord returns the record of a migration applied now. duration is nil
+// when the migration was marked as applied without running it.
+func newRecord(migration *Migration, duration *time.Duration) *MigrationRecord {
+	return &MigrationRecord{Id: migration.Id, AppliedAt: time.Now(), Checksum: migration.Checksum(), Duration: duration}
+}
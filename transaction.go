@@ -0,0 +1,130 @@
+package migration
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NonTransactionalPolicy decides what happens to migrations containing
+// statements the dialect can not run inside a transaction, such as
+// CREATE INDEX CONCURRENTLY on postgresql, when they are not marked
+// notransaction.
+type NonTransactionalPolicy int
+
+const (
+	// NonTransactionalFail refuses to plan the migrations, so that the
+	// author decides whether the migration may be applied partially.
+	NonTransactionalFail NonTransactionalPolicy = iota
+	// NonTransactionalAuto runs the affected direction without a transaction.
+	NonTransactionalAuto
+	// NonTransactionalIgnore keeps the declared transaction mode.
+	NonTransactionalIgnore
+)
+
+// nonTransactionalPolicies maps the names of the policies, as given to the
+// -non-transactional flag, to the policies.
+var nonTransactionalPolicies = map[string]NonTransactionalPolicy{
+	"fail":   NonTransactionalFail,
+	"auto":   NonTransactionalAuto,
+	"ignore": NonTransactionalIgnore,
+}
+
+// ParseNonTransactionalPolicy returns the policy called name: fail, auto or
+// ignore.
+func ParseNonTransactionalPolicy(name string) (NonTransactionalPolicy, error) {
+	p, ok := nonTransactionalPolicies[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("Unknown non-transactional policy: %s (fail, auto or ignore)", name)
+	}
+	return p, nil
+}
+
+// nonTransactionalStatements lists, per dialect, the statements that are
+// rejected inside a transaction block.
+var nonTransactionalStatements = map[string][]*regexp.Regexp{
+	"postgresql": {
+		regexp.MustCompile(`(?is)^\s*(CREATE|DROP)\s+(UNIQUE\s+)?INDEX\s+CONCURRENTLY\b`),
+		regexp.MustCompile(`(?is)^\s*REINDEX\b.*\bCONCURRENTLY\b`),
+		regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\b.*\bDETACH\s+PARTITION\b.*\bCONCURRENTLY\b`),
+		regexp.MustCompile(`(?is)^\s*VACUUM\b`),
+		regexp.MustCompile(`(?is)^\s*ALTER\s+TYPE\b.*\bADD\s+VALUE\b`),
+		regexp.MustCompile(`(?is)^\s*(CREATE|DROP)\s+(DATABASE|TABLESPACE)\b`),
+		regexp.MustCompile(`(?is)^\s*ALTER\s+SYSTEM\b`),
+	},
+	"sqlite3": {
+		regexp.MustCompile(`(?is)^\s*VACUUM\b`),
+	},
+}
+
+// NonTransactionalStatements returns the statements among stmts that the
+// dialect can not run inside a transaction. With an empty dialect, it
+// returns those some dialect can not.
+func NonTransactionalStatements(dialect string, stmts []string) []string {
+	var patterns []*regexp.Regexp
+	if dialect == "" {
+		for _, p := range nonTransactionalStatements {
+			patterns = append(patterns, p...)
+		}
+	} else {
+		patterns = nonTransactionalStatements[CanonicalDialect(dialect)]
+	}
+
+	var result []string
+	for _, stmt := range stmts {
+		sql := stripSQLComments(stmt)
+		for _, re := range patterns {
+			if re.MatchString(sql) {
+				result = append(result, stmt)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// nonTransactional returns the statements of a direction of m that the
+// dialect can not run inside a transaction, among the detected ones when m
+// was parsed by ParseMigration.
+func nonTransactional(dialect string, m *Migration, stmts, detected []string) []string {
+	if m.parsed {
+		stmts = detected
+	}
+	return NonTransactionalStatements(dialect, stmts)
+}
+
+// applyTransactionPolicy checks the migrations against the policy of the set.
+// Migrations are copied before being changed, so sources can share them.
+func (ms MigrationSet) applyTransactionPolicy(dialect string, migrations []*Migration) ([]*Migration, error) {
+	if ms.NonTransactional == NonTransactionalIgnore {
+		return migrations, nil
+	}
+
+	for i, m := range migrations {
+		var up, down []string
+		if !m.DisableTransactionUp {
+			up = nonTransactional(dialect, m, m.Up, m.NonTransactionalUp)
+		}
+		if !m.DisableTransactionDown {
+			down = nonTransactional(dialect, m, m.Down, m.NonTransactionalDown)
+		}
+		if len(up) == 0 && len(down) == 0 {
+			continue
+		}
+
+		if ms.NonTransactional == NonTransactionalFail {
+			direction, stmt := "Up", up
+			if len(up) == 0 {
+				direction, stmt = "Down", down
+			}
+			return nil, fmt.Errorf("Migration %s can not run %q inside a transaction on %s. Add notransaction to its '-- +migrate %s' line",
+				m.Id, strings.TrimSpace(stmt[0]), dialect, direction)
+		}
+
+		c := *m
+		c.DisableTransactionUp = c.DisableTransactionUp || len(up) > 0
+		c.DisableTransactionDown = c.DisableTransactionDown || len(down) > 0
+		migrations[i] = &c
+	}
+	return migrations, nil
+}
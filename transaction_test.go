@@ -0,0 +1,167 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseNonTransactionalPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		want NonTransactionalPolicy
+		err  bool
+	}{
+		{"fail", NonTransactionalFail, false},
+		{"auto", NonTransactionalAuto, false},
+		{"Ignore", NonTransactionalIgnore, false},
+		{"skip", 0, true},
+		{"", 0, true},
+	}
+	for _, test := range tests {
+		got, err := ParseNonTransactionalPolicy(test.name)
+		if (err != nil) != test.err || got != test.want {
+			t.Errorf("ParseNonTransactionalPolicy(%q) = %v, %v", test.name, got, err)
+		}
+	}
+	var zero NonTransactionalPolicy
+	if zero != NonTransactionalFail {
+		t.Error("the zero policy is not NonTransactionalFail")
+	}
+}
+
+func TestNonTransactionalStatements(t *testing.T) {
+	tests := []struct {
+		dialect string
+		stmt    string
+		want    bool
+	}{
+		{"postgresql", "CREATE INDEX CONCURRENTLY users_name ON users (name)", true},
+		{"postgresql", "create unique index concurrently users_name on users (name)", true},
+		{"postgresql", "DROP INDEX CONCURRENTLY users_name", true},
+		{"postgresql", "CREATE INDEX users_name ON users (name)", false},
+		{"postgresql", "REINDEX INDEX CONCURRENTLY users_name", true},
+		{"postgresql", "ALTER TABLE events DETACH PARTITION events_2020 CONCURRENTLY", true},
+		{"postgresql", "VACUUM ANALYZE users", true},
+		{"postgresql", "ALTER TYPE mood ADD VALUE 'meh'", true},
+		{"postgresql", "CREATE DATABASE app", true},
+		{"postgresql", "ALTER SYSTEM SET work_mem = '64MB'", true},
+		{"postgresql", "-- VACUUM later\nSELECT 1", false},
+		{"sqlite3", "VACUUM", true},
+		{"sqlite3", "CREATE INDEX CONCURRENTLY users_name ON users (name)", false},
+		{"mysql", "VACUUM", false},
+		{"", "CREATE INDEX CONCURRENTLY users_name ON users (name)", true},
+		{"", "VACUUM", true},
+		{"", "CREATE TABLE users (id int)", false},
+	}
+	for _, test := range tests {
+		got := len(NonTransactionalStatements(test.dialect, []string{test.stmt})) > 0
+		if got != test.want {
+			t.Errorf("NonTransactionalStatements(%q, %q) = %v, want %v", test.dialect, test.stmt, got, test.want)
+		}
+	}
+}
+
+func TestApplyTransactionPolicy(t *testing.T) {
+	index := &Migration{
+		Id:   "1_index_users.sql",
+		Up:   []string{"CREATE INDEX CONCURRENTLY users_name ON users (name)"},
+		Down: []string{"DROP INDEX users_name"},
+	}
+	marked := &Migration{Id: index.Id, Up: index.Up, Down: index.Down, DisableTransactionUp: true}
+	drop := &Migration{
+		Id:   "2_drop_index.sql",
+		Up:   []string{"DROP INDEX users_name"},
+		Down: []string{"CREATE INDEX CONCURRENTLY users_name ON users (name)"},
+	}
+
+	tests := []struct {
+		name      string
+		policy    NonTransactionalPolicy
+		migration *Migration
+		up, down  bool
+		err       string
+	}{
+		{"fail", NonTransactionalFail, index, false, false, `Migration 1_index_users.sql can not run "CREATE INDEX CONCURRENTLY users_name ON users (name)" inside a transaction on postgresql. Add notransaction to its '-- +migrate Up' line`},
+		{"fail down", NonTransactionalFail, drop, false, false, "Add notransaction to its '-- +migrate Down' line"},
+		{"fail marked", NonTransactionalFail, marked, true, false, ""},
+		{"auto", NonTransactionalAuto, index, true, false, ""},
+		{"auto down", NonTransactionalAuto, drop, false, true, ""},
+		{"ignore", NonTransactionalIgnore, index, false, false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ms := MigrationSet{NonTransactional: test.policy}
+			migrations, err := ms.applyTransactionPolicy("postgresql", []*Migration{test.migration})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got %v, want %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := migrations[0]
+			if m.DisableTransactionUp != test.up || m.DisableTransactionDown != test.down {
+				t.Errorf("notransaction up %v, down %v, want %v, %v", m.DisableTransactionUp, m.DisableTransactionDown, test.up, test.down)
+			}
+		})
+	}
+
+	// The source migration is left unchanged.
+	if index.DisableTransactionUp {
+		t.Error("auto changed the migration of the source")
+	}
+}
+
+func TestNonTransactionalDetectedWhenParsed(t *testing.T) {
+	content := `-- +migrate Up
+-- +migrate StatementBegin
+CREATE TRIGGER users_touch AFTER UPDATE ON users BEGIN
+	VACUUM;
+END;
+-- +migrate StatementEnd
+VACUUM;
+
+-- +migrate Down notransaction
+VACUUM;
+`
+	m, err := ParseMigration("1_vacuum.sql", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.NonTransactionalUp) != 1 || !strings.HasPrefix(m.NonTransactionalUp[0], "VACUUM") {
+		t.Errorf("NonTransactionalUp = %q", m.NonTransactionalUp)
+	}
+	// Marked notransaction, nothing is detected.
+	if len(m.NonTransactionalDown) != 0 {
+		t.Errorf("NonTransactionalDown = %q", m.NonTransactionalDown)
+	}
+
+	_, err = MigrationSet{}.applyTransactionPolicy("sqlite3", []*Migration{m})
+	if err == nil || !strings.Contains(err.Error(), "Migration 1_vacuum.sql can not run \"VACUUM") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestExecNonTransactional(t *testing.T) {
+	useMockUi(t)
+	source := MemoryMigrationSource{Migrations: []*Migration{
+		{Id: "1_create_users.sql", Up: []string{"CREATE TABLE users (id INTEGER PRIMARY KEY)"}},
+		{Id: "2_vacuum.sql", Up: []string{"VACUUM"}},
+	}}
+
+	db := openTestDB(t)
+	applied, err := MigrationSet{}.Exec(db, "sqlite3", source, Up)
+	if applied != 0 || err == nil {
+		t.Fatalf("up with the fail policy = %d, %v", applied, err)
+	}
+	if tableExists(t, db, "users") {
+		t.Error("the fail policy applied migrations")
+	}
+
+	applied, err = MigrationSet{NonTransactional: NonTransactionalAuto}.Exec(db, "sqlite3", source, Up)
+	if applied != 2 || err != nil {
+		t.Fatalf("up with the auto policy = %d, %v", applied, err)
+	}
+}
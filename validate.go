@@ -0,0 +1,117 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ValidateError is returned by Validate when a planned statement fails. It
+// contains the relevant *Migration and the statement that failed.
+type ValidateError struct {
+	Migration *Migration
+	Statement string
+	Err       error
+}
+
+func (e *ValidateError) Error() string {
+	return fmt.Sprintf("%s handling %s: %s", e.Err.Error(), e.Migration.Id, strings.TrimSpace(e.Statement))
+}
+
+func (e *ValidateError) Unwrap() error {
+	return e.Err
+}
+
+// Validate executes the planned migrations, tracking table changes included,
+// inside a single transaction that is always rolled back.
+//
+// Returns the number of migrations that executed successfully.
+func Validate(db *sql.DB, dialect string, m MigrationSource, dir MigrationDirection, max int) (int, error) {
+	return migSet.Validate(db, dialect, m, dir, max)
+}
+
+// Validate Returns the number of migrations that executed successfully.
+func (ms MigrationSet) Validate(db *sql.DB, dialect string, m MigrationSource, dir MigrationDirection, max int) (int, error) {
+	dialect = CanonicalDialect(dialect)
+
+	d, err := GetDialect(dialect)
+	if err != nil {
+		return 0, err
+	}
+	if !d.TransactionalDDL() {
+		return 0, fmt.Errorf("Cannot validate: dialect %s does not support transactional DDL", dialect)
+	}
+	if ms.SearchPath != "" && dialect != "postgresql" {
+		return 0, fmt.Errorf("SearchPath is not supported by dialect: %s", dialect)
+	}
+
+	// The tracking table is not created on a fresh database, where nothing
+	// is applied, but in the transaction below.
+	table, err := ms.existingTrackingTable(db, dialect)
+	if err != nil {
+		return 0, err
+	}
+	records, err := table.Records()
+	missing := err != nil && d.ClassifyError(err) == ErrorUndefinedObject
+	if err != nil && !missing {
+		return 0, err
+	}
+	migrations, err := ms.plan(dialect, m, records, dir, max)
+	if err != nil {
+		return 0, err
+	}
+	for _, migration := range migrations {
+		if migration.DisableTransaction {
+			return 0, fmt.Errorf("Cannot validate: migration %s runs without a transaction", migration.Id)
+		}
+	}
+
+	trans, err := table.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = trans.Rollback() }()
+
+	if missing {
+		for _, stmt := range d.CreateTrackingTable(table.SchemaName, table.TableName) {
+			if _, err := trans.Exec(stmt); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if ms.SearchPath != "" {
+		if _, err := trans.Exec("SET LOCAL search_path TO " + ms.SearchPath); err != nil {
+			return 0, err
+		}
+	}
+
+	validated := 0
+	for _, migration := range migrations {
+		for _, stmt := range migration.Queries {
+			stmt = strings.TrimSuffix(stmt, "\n")
+			stmt = strings.TrimSuffix(stmt, " ")
+			stmt = strings.TrimSuffix(stmt, ";")
+			if _, err := trans.Exec(stmt); err != nil {
+				return validated, &ValidateError{Migration: migration.Migration, Statement: stmt, Err: err}
+			}
+		}
+
+		switch migration.Direction {
+		case Up:
+			err = table.Insert(trans, newRecord(migration.Migration, nil))
+		case Down:
+			err = table.Delete(trans, migration.Id)
+		default:
+			panic("Not possible")
+		}
+		if err != nil {
+			return validated, &ValidateError{Migration: migration.Migration, Statement: "(tracking table)", Err: err}
+		}
+
+		ui.Output("Validated " + migration.Id)
+		validated++
+	}
+
+	return validated, nil
+}
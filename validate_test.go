@@ -0,0 +1,114 @@
+package migration
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+// openTestDB opens a sqlite3 database in a temporary file.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Connect("sqlite3", filepath.Join(t.TempDir(), "app.db"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// useMockUi captures the output of the package until the test ends.
+func useMockUi(t *testing.T) *cli.MockUi {
+	t.Helper()
+	previous := ui
+	mock := cli.NewMockUi()
+	ui = mock
+	t.Cleanup(func() { ui = previous })
+	return mock
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func TestValidate(t *testing.T) {
+	create := &Migration{Id: "1_create_users.sql", Up: []string{"CREATE TABLE users (id INTEGER PRIMARY KEY)"}, Down: []string{"DROP TABLE users"}}
+	insert := &Migration{Id: "2_insert_users.sql", Up: []string{"INSERT INTO users (id) VALUES (1)"}, Down: []string{"DELETE FROM users"}}
+	broken := &Migration{Id: "2_insert_accounts.sql", Up: []string{"INSERT INTO accounts (id) VALUES (1)"}, Down: []string{"DELETE FROM accounts"}}
+	vacuum := &Migration{Id: "2_vacuum.sql", Up: []string{"VACUUM"}, DisableTransactionUp: true}
+
+	tests := []struct {
+		name       string
+		dialect    string
+		migrations []*Migration
+		validated  int
+		err        string
+	}{
+		{"valid", "sqlite3", []*Migration{create, insert}, 2, ""},
+		{"failing statement", "sqlite3", []*Migration{create, broken}, 1, "handling 2_insert_accounts.sql: INSERT INTO accounts (id) VALUES (1)"},
+		{"notransaction", "sqlite3", []*Migration{create, vacuum}, 0, "Cannot validate: migration 2_vacuum.sql runs without a transaction"},
+		{"no transactional DDL", "mysql", []*Migration{create}, 0, "Cannot validate: dialect mysql does not support transactional DDL"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useMockUi(t)
+			db := openTestDB(t)
+			source := MemoryMigrationSource{Migrations: test.migrations}
+
+			validated, err := MigrationSet{}.Validate(db, test.dialect, source, Up, 0)
+			if validated != test.validated {
+				t.Errorf("validated %d migrations, want %d", validated, test.validated)
+			}
+			switch {
+			case test.err == "" && err != nil:
+				t.Fatal(err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Fatalf("got error %v, want %s", err, test.err)
+			}
+			var validateErr *ValidateError
+			if strings.HasPrefix(test.name, "failing") && !errors.As(err, &validateErr) {
+				t.Errorf("got %T, want *ValidateError", err)
+			}
+
+			// Everything is rolled back, the tracking table included.
+			for _, table := range []string{"users", "gorp_migrations"} {
+				if tableExists(t, db, table) {
+					t.Errorf("%s exists after validate", table)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateDown(t *testing.T) {
+	useMockUi(t)
+	db := openTestDB(t)
+	source := MemoryMigrationSource{Migrations: []*Migration{
+		{Id: "1_create_users.sql", Up: []string{"CREATE TABLE users (id INTEGER PRIMARY KEY)"}, Down: []string{"DROP TABLE users"}},
+	}}
+	ms := MigrationSet{AllowDestructive: true}
+	if _, err := ms.Exec(db, "sqlite3", source, Up); err != nil {
+		t.Fatal(err)
+	}
+
+	validated, err := ms.Validate(db, "sqlite3", source, Down, 0)
+	if err != nil || validated != 1 {
+		t.Fatalf("Validate down = %d, %v", validated, err)
+	}
+	if !tableExists(t, db, "users") {
+		t.Error("users dropped by validate")
+	}
+	records, err := ms.GetMigrationRecords(db, "sqlite3")
+	if err != nil || len(records) != 1 {
+		t.Errorf("records after validate = %v, %v", records, err)
+	}
+}